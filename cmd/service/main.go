@@ -2,112 +2,234 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
 
+	"github.com/seggga/postgres/pkg/config"
+	"github.com/seggga/postgres/pkg/logging"
+	"github.com/seggga/postgres/pkg/metrics"
+	"github.com/seggga/postgres/pkg/tracing"
 	videoHint "github.com/seggga/postgres/pkg/video-hint/http"
 	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
 
 func main() {
-	srv, err := initServer()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("[ERR]: migrate: %v", err)
+		}
+		return
+	}
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "usage: service [flags]\n       service migrate <command>\n\nflags:\n%s", config.Usage())
+		return
+	}
 	if err != nil {
-		log.Fatalf("[ERR]: failed to initialize server: %v", err)
+		log.Fatalf("[ERR]: failed to load config: %v", err)
 	}
-	log.Println("Let's Go!")
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("[ERR]: %v", err)
+	logger := newLogger(cfg)
+	if cfg.DB.AutoMigrate {
+		if err := autoMigrate(cfg, logger); err != nil {
+			logger.Fatalf("failed to migrate the DB: %v", err)
+		}
 	}
-}
-
-func initServer() (*http.Server, error) {
-	handler, err := registerRoutes()
+	if err := verifyMigrations(cfg, logger); err != nil {
+		logger.Fatal(err)
+	}
+	shutdownTracing, err := initTracing(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to register routes: %w", err)
+		logger.Fatalf("failed to initialize tracing: %v", err)
 	}
-	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: handler,
+	m := metrics.New()
+	db, err := initDB(cfg, logger, m)
+	if err != nil {
+		logger.Fatalf("failed to initialize DB: %v", err)
 	}
-	return srv, nil
-}
-
-func registerRoutes() (http.Handler, error) {
-	r := mux.NewRouter()
-	r.HandleFunc("/video/{captionSubstring}", func(w http.ResponseWriter, r *http.Request) {
-		videoHint.GetVideosByCaption(w, r, mux.Vars(r)["captionSubstring"])
-	}).Methods("GET")
-	addDBMiddleware, err := createAddDBMiddleware()
+	// the pool closes once serve has waited for the last request
+	defer db.Close()
+	schemaVersion, err := latestVersion()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AddDBMiddleware: %w", err)
+		logger.Fatal(err)
 	}
-	r.Use(addDBMiddleware)
-	return r, err
-}
-
-func createAddDBMiddleware() (func(next http.Handler) http.Handler, error) {
-	connStr, err := getConnString()
+	ready := videoHint.NewReadiness(db, videoHint.ReadinessConfig{SchemaVersion: schemaVersion, Logger: logger})
+	srv, err := initServer(cfg, db, logger, ready, m)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get connection string info for DB connection: %w", err)
+		logger.Fatalf("failed to initialize server: %v", err)
+	}
+	logger.Infof("Let's Go! Listening on %s", cfg.HTTP.Addr)
+	if err := serve(cfg, srv, logger, ready); err != nil {
+		logger.Error(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Errorf("failed to flush the spans: %v", err)
 	}
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			db, err := storage.NewDB(connStr)
-			if err != nil {
-				log.Println("[ERR]: ", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			defer db.Close()
-			r = r.WithContext(context.WithValue(r.Context(), storage.ContextKeyDB, db))
-
-			next.ServeHTTP(w, r)
-		})
-	}, nil
 }
 
-const (
-	dbConnVarNameHost     = "DB_HOST"
-	dbConnVarNamePort     = "DB_PORT"
-	dbConnVarNameUser     = "DB_USER"
-	dbConnVarNamePassword = "DB_PASSWORD"
-	dbConnVarNameDBName   = "DB_NAME"
-)
+// serve drains the server on SIGTERM or SIGINT, a second signal kills the process right away
+func serve(cfg *config.Config, srv *http.Server, logger *logrus.Logger, ready *videoHint.Readiness) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-func getConnString() (*storage.ConnString, error) {
-	fnLookupVar := func(varName string) (string, error) {
-		val, ok := os.LookupEnv(varName)
-		if !ok {
-			return "", fmt.Errorf("variable %s is not defined", varName)
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.HTTP.TLS() {
+			errc <- srv.ListenAndServeTLS(cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile)
+			return
 		}
-		return val, nil
+		errc <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
 	}
-	connStr := &storage.ConnString{}
-	var err error
-	connStr.Host, err = fnLookupVar(dbConnVarNameHost)
-	if err != nil {
-		return nil, err
+	stop()
+
+	logger.Infof("shutting down, draining the requests for up to %v", cfg.HTTP.ShutdownDelay+cfg.HTTP.ShutdownTimeout)
+	ready.Drain()
+	time.Sleep(cfg.HTTP.ShutdownDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to drain the requests: %w", err)
 	}
-	connStr.Port, err = fnLookupVar(dbConnVarNamePort)
-	if err != nil {
-		return nil, err
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
-	connStr.User, err = fnLookupVar(dbConnVarNameUser)
+	logger.Info("all requests are served")
+	return nil
+}
+
+func initDB(cfg *config.Config, logger *logrus.Logger, m *metrics.Metrics) (storage.DB, error) {
+	storageCfg := cfg.StorageConfig()
+	storageCfg.Logger = logger
+	storageCfg.Observer = m
+	db, err := storage.NewDB(storageCfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create a DB connection pool: %w", err)
 	}
-	connStr.Password, err = fnLookupVar(dbConnVarNamePassword)
+	m.RegisterPool(db)
+	return db, nil
+}
+
+// initTracing installs the propagator and the tracer provider globally, otelmux takes them from there
+func initTracing(cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(tracing.Propagator())
+	if cfg.Tracing.Exporter == tracing.ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+	tp, err := tracing.NewProvider(context.Background(), cfg.TracingConfig())
 	if err != nil {
 		return nil, err
 	}
-	connStr.DBName, err = fnLookupVar(dbConnVarNameDBName)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func newLogger(cfg *config.Config) *logrus.Logger {
+	return logging.New(os.Stdout, cfg.LogLevel())
+}
+
+func initServer(cfg *config.Config, db storage.DB, logger *logrus.Logger, ready *videoHint.Readiness, m *metrics.Metrics) (*http.Server, error) {
+	handler, err := registerRoutes(cfg, db, logger, ready, m)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to register routes: %w", err)
+	}
+	srv := &http.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+		ErrorLog:     log.New(logger.WriterLevel(logrus.ErrorLevel), "", 0),
+	}
+	return srv, nil
+}
+
+func registerRoutes(cfg *config.Config, db storage.DB, logger *logrus.Logger, ready *videoHint.Readiness, m *metrics.Metrics) (http.Handler, error) {
+	h := videoHint.NewHandler(db, videoHint.Config{
+		PageLimits:          cfg.PageLimits(),
+		SimilarityThreshold: cfg.API.SimilarityThreshold,
+		SearchObserver:      m,
+	})
+	root := mux.NewRouter()
+	// the probes and the metrics stay out of the API middleware
+	root.HandleFunc("/healthz", videoHint.Liveness).Methods("GET")
+	root.Handle("/readyz", ready).Methods("GET")
+	root.Handle("/metrics", m.Handler()).Methods("GET")
+	middleware := []mux.MiddlewareFunc{otelmux.Middleware(cfg.Tracing.ServiceName), logging.Middleware(logger), m.Middleware}
+	r := root.PathPrefix("/").Subrouter()
+	r.Use(middleware...)
+	// mux runs the middleware on matched routes only
+	root.NotFoundHandler = chain(http.HandlerFunc(videoHint.NotFound), middleware)
+	root.MethodNotAllowedHandler = chain(http.HandlerFunc(videoHint.MethodNotAllowed), middleware)
+	r.HandleFunc("/video/{captionSubstring}", func(w http.ResponseWriter, r *http.Request) {
+		h.GetVideosByCaption(w, r, mux.Vars(r)["captionSubstring"])
+	}).Methods("GET")
+	r.HandleFunc("/videos", h.CreateVideo).Methods("POST")
+	r.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.GetVideo(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.UpdateVideo(w, r, mux.Vars(r)["id"])
+	}).Methods("PATCH")
+	r.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.DeleteVideo(w, r, mux.Vars(r)["id"])
+	}).Methods("DELETE")
+	r.HandleFunc("/users", h.RegisterUser).Methods("POST")
+	r.HandleFunc("/users", h.ListUsers).Methods("GET")
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.GetUser(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.UpdateUser(w, r, mux.Vars(r)["id"])
+	}).Methods("PATCH")
+	r.HandleFunc("/videos/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		h.PostComment(w, r, mux.Vars(r)["id"])
+	}).Methods("POST")
+	r.HandleFunc("/videos/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		h.ListComments(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/videos/{id}/comments/count", func(w http.ResponseWriter, r *http.Request) {
+		h.CountComments(w, r, mux.Vars(r)["id"])
+	}).Methods("GET")
+	r.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.EditComment(w, r, mux.Vars(r)["id"])
+	}).Methods("PATCH")
+	r.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		h.DeleteComment(w, r, mux.Vars(r)["id"])
+	}).Methods("DELETE")
+	r.HandleFunc("/videos/{id}/like", func(w http.ResponseWriter, r *http.Request) {
+		h.Like(w, r, mux.Vars(r)["id"])
+	}).Methods("PUT")
+	r.HandleFunc("/videos/{id}/dislike", func(w http.ResponseWriter, r *http.Request) {
+		h.Dislike(w, r, mux.Vars(r)["id"])
+	}).Methods("PUT")
+	r.HandleFunc("/videos/{id}/vote", func(w http.ResponseWriter, r *http.Request) {
+		h.Unvote(w, r, mux.Vars(r)["id"])
+	}).Methods("DELETE")
+	return root, nil
+}
+
+func chain(h http.Handler, middleware []mux.MiddlewareFunc) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
 	}
-	return connStr, nil
+	return h
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/seggga/postgres/migrations"
+	"github.com/seggga/postgres/pkg/config"
+	"github.com/seggga/postgres/pkg/migrate"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const migrateUsage = `usage: service migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [N]   roll back N migrations, 1 by default
+  status     print the applied version and pending migrations
+  goto N     migrate up or down to version N
+  verify     check the embedded migrations against the applied ones`
+
+// runMigrate handles the migrate subcommand, args are the arguments following it
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no migrate command given\n%s", migrateUsage)
+	}
+	// the migrate commands take no flags, the config comes from the file and env vars
+	cfg, err := config.Load(nil, os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	ctx := context.Background()
+	m, closeConn, err := newMigrator(ctx, cfg, newLogger(cfg))
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "up" && len(rest) == 0:
+		return m.Up(ctx)
+	case cmd == "down" && len(rest) <= 1:
+		n := 1
+		if len(rest) == 1 {
+			n, err = strconv.Atoi(rest[0])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("the number of migrations to roll back must be a positive integer, got %q", rest[0])
+			}
+		}
+		return m.Down(ctx, n)
+	case cmd == "goto" && len(rest) == 1:
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed migration version %q", rest[0])
+		}
+		return m.Goto(ctx, version)
+	case cmd == "status" && len(rest) == 0:
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(os.Stdout, status)
+	case cmd == "verify" && len(rest) == 0:
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+		fmt.Println("migrations verified")
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", strings.Join(args, " "), migrateUsage)
+	}
+}
+
+// autoMigrate applies pending migrations, replicas wait for each other on the advisory lock
+func autoMigrate(cfg *config.Config, logger *logrus.Logger) error {
+	ctx := context.Background()
+	m, closeConn, err := newMigrator(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	return m.Up(ctx)
+}
+
+func verifyMigrations(cfg *config.Config, logger *logrus.Logger) error {
+	ctx := context.Background()
+	m, closeConn, err := newMigrator(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	newer, err := m.VerifyCompatible(ctx)
+	if err != nil {
+		return err
+	}
+	if newer {
+		logger.Warn("the DB schema is newer than the migrations of the build")
+	}
+	return nil
+}
+
+func newMigrator(ctx context.Context, cfg *config.Config, logger *logrus.Logger) (*migrate.Migrator, func(), error) {
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the embedded migrations: %w", err)
+	}
+	conn, err := storage.ConnectPGX(ctx, cfg.StorageConfig().ConnString)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeConn := func() {
+		conn.Close(context.Background())
+	}
+	return migrate.New(conn, ms, logger), closeConn, nil
+}
+
+func printStatus(out io.Writer, s *migrate.Status) error {
+	version := "none"
+	if s.Version != migrate.NilVersion {
+		version = strconv.FormatInt(s.Version, 10)
+	}
+	if s.Dirty {
+		version += " (dirty)"
+	}
+	fmt.Fprintf(out, "version: %s\n\n", version)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, m := range s.Migrations {
+		state := "pending"
+		if m.Applied {
+			state = "applied"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", m.Migration, state)
+	}
+	return w.Flush()
+}
+
+// latestVersion is the version of the last embedded migration, the one the build expects
+func latestVersion() (int64, error) {
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load the migrations: %w", err)
+	}
+	if len(ms) == 0 {
+		return migrate.NilVersion, nil
+	}
+	return ms[len(ms)-1].Version, nil
+}
@@ -1,8 +1,9 @@
 BEGIN;
 
-DROP TABLE users;
-DROP TABLE videos;
-DROP TABLE comments;
 DROP TABLE likes;
+DROP TABLE comments;
+DROP TABLE videos;
+DROP TABLE users;
+DROP TYPE resolution;
 
-COMMIT;
\ No newline at end of file
+COMMIT;
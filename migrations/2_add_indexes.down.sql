@@ -1,7 +1,5 @@
-BEGIN;
+-- migrate:no-transaction
 
-DROP INDEX videos_created_at_idx;
-DROP INDEX comments_created_at_idx;
-DROP INDEX videos_caption_idx;
-
-COMMIT;
\ No newline at end of file
+DROP INDEX CONCURRENTLY videos_created_at_idx;
+DROP INDEX CONCURRENTLY comments_created_at_idx;
+DROP INDEX CONCURRENTLY videos_caption_idx;
@@ -1,7 +1,5 @@
-BEGIN;
+-- migrate:no-transaction
 
-CREATE INDEX concurrently videos_created_at_idx ON videos USING BTREE (created_at);
-CREATE INDEX concurrently comments_created_at_idx ON comments USING BTREE (created_at);
-CREATE INDEX concurrently videos_caption_idx ON videos USING BTREE (caption text_pattern_ops);
-
-COMMIT;
\ No newline at end of file
+CREATE INDEX CONCURRENTLY videos_created_at_idx ON videos USING BTREE (created_at);
+CREATE INDEX CONCURRENTLY comments_created_at_idx ON comments USING BTREE (created_at);
+CREATE INDEX CONCURRENTLY videos_caption_idx ON videos USING BTREE (caption text_pattern_ops);
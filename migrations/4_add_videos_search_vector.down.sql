@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+
+DROP INDEX CONCURRENTLY IF EXISTS videos_search_vector_idx;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
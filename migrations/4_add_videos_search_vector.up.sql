@@ -0,0 +1,10 @@
+-- migrate:no-transaction
+
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', caption), 'A') ||
+    setweight(to_tsvector('english', description), 'B')
+) STORED;
+
+-- a failed concurrent build leaves an invalid index behind, a retry rebuilds it
+DROP INDEX CONCURRENTLY IF EXISTS videos_search_vector_idx;
+CREATE INDEX CONCURRENTLY videos_search_vector_idx ON videos USING GIN (search_vector);
@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+
+DROP INDEX CONCURRENTLY videos_caption_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX CONCURRENTLY videos_caption_trgm_idx ON videos USING GIN (caption gin_trgm_ops);
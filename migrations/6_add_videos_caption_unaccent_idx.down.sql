@@ -0,0 +1,5 @@
+-- migrate:no-transaction
+
+DROP INDEX CONCURRENTLY IF EXISTS videos_caption_lower_unaccent_idx;
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
+DROP EXTENSION IF EXISTS unaccent;
@@ -0,0 +1,13 @@
+-- migrate:no-transaction
+
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is STABLE only, since its dictionary may change, so it can not be indexed.
+-- The wrapper pins the dictionary and is declared IMMUTABLE to be usable in the index
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+    AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+-- a failed concurrent build leaves an invalid index behind, a retry rebuilds it
+DROP INDEX CONCURRENTLY IF EXISTS videos_caption_lower_unaccent_idx;
+CREATE INDEX CONCURRENTLY videos_caption_lower_unaccent_idx ON videos USING GIN (lower(immutable_unaccent(caption)) gin_trgm_ops);
@@ -0,0 +1,3 @@
+-- migrate:no-transaction
+
+DROP INDEX CONCURRENTLY comments_video_id_created_at_idx;
@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+
+-- serves listing and counting the comments of one video in the created_at order
+CREATE INDEX CONCURRENTLY comments_video_id_created_at_idx ON comments USING BTREE (video_id, created_at, id);
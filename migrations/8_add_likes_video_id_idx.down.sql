@@ -0,0 +1,3 @@
+-- migrate:no-transaction
+
+DROP INDEX CONCURRENTLY likes_video_id_idx;
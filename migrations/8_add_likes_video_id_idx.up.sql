@@ -0,0 +1,4 @@
+-- migrate:no-transaction
+
+-- the primary key leads with user_id, counting the votes of a video needs its own index
+CREATE INDEX CONCURRENTLY likes_video_id_idx ON likes USING BTREE (video_id, thumb_up);
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations into the service binary
+package migrations
+
+import "embed"
+
+// FS holds the {version}_{name}.up.sql and {version}_{name}.down.sql files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,276 @@
+// Package config loads the settings, env vars override the file and the file overrides flags
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/seggga/postgres/pkg/tracing"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+type Config struct {
+	HTTP    HTTPConfig    `yaml:"http" toml:"http"`
+	DB      DBConfig      `yaml:"db" toml:"db"`
+	API     APIConfig     `yaml:"api" toml:"api"`
+	Log     LogConfig     `yaml:"log" toml:"log"`
+	Tracing TracingConfig `yaml:"tracing" toml:"tracing"`
+}
+
+// HTTPConfig describes the listener, the server uses TLS if both TLS files are set
+type HTTPConfig struct {
+	Addr            string        `yaml:"addr" toml:"addr"`
+	TLSCertFile     string        `yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile      string        `yaml:"tls_key_file" toml:"tls_key_file"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// TLS tells whether the server has to serve HTTPS
+func (c *HTTPConfig) TLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// DBConfig describes the DB connection, the settings override the matching parts of URL
+type DBConfig struct {
+	Driver           string        `yaml:"driver" toml:"driver"`
+	URL              string        `yaml:"url" toml:"url"`
+	Host             string        `yaml:"host" toml:"host"`
+	Port             string        `yaml:"port" toml:"port"`
+	User             string        `yaml:"user" toml:"user"`
+	Password         string        `yaml:"password" toml:"password"`
+	Name             string        `yaml:"name" toml:"name"`
+	SSLMode          string        `yaml:"sslmode" toml:"sslmode"`
+	SSLRootCert      string        `yaml:"sslrootcert" toml:"sslrootcert"`
+	SSLCert          string        `yaml:"sslcert" toml:"sslcert"`
+	SSLKey           string        `yaml:"sslkey" toml:"sslkey"`
+	TimeZone         string        `yaml:"timezone" toml:"timezone"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" toml:"statement_timeout"`
+	AutoMigrate      bool          `yaml:"auto_migrate" toml:"auto_migrate"`
+	Pool             PoolConfig    `yaml:"pool" toml:"pool"`
+}
+
+type PoolConfig struct {
+	MaxConns        int           `yaml:"max_conns" toml:"max_conns"`
+	MinConns        int           `yaml:"min_conns" toml:"min_conns"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" toml:"max_conn_idle_time"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" toml:"max_conn_lifetime"`
+}
+
+type APIConfig struct {
+	DefaultPageSize     int     `yaml:"default_page_size" toml:"default_page_size"`
+	MaxPageSize         int     `yaml:"max_page_size" toml:"max_page_size"`
+	SimilarityThreshold float64 `yaml:"similarity_threshold" toml:"similarity_threshold"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// TracingConfig selects the trace exporter: none, stdout or otlp
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" toml:"exporter"`
+	ServiceName  string  `yaml:"service_name" toml:"service_name"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" toml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// Default returns the settings used when nothing else is set
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Addr:            ":8080",
+			ReadTimeout:     time.Second * 10,
+			WriteTimeout:    time.Second * 30,
+			IdleTimeout:     time.Minute * 2,
+			ShutdownTimeout: time.Second * 30,
+		},
+		DB: DBConfig{
+			Driver:           string(storage.DriverPGX),
+			ConnectTimeout:   time.Second * 5,
+			StatementTimeout: time.Second * 5,
+			Pool: PoolConfig{
+				MaxConns:        10,
+				MinConns:        2,
+				MaxConnIdleTime: time.Minute * 5,
+				MaxConnLifetime: time.Hour,
+			},
+		},
+		API: APIConfig{
+			DefaultPageSize:     service.DefaultPageLimits.Default,
+			MaxPageSize:         service.DefaultPageLimits.Max,
+			SimilarityThreshold: service.DefaultSimilarityThreshold,
+		},
+		Log: LogConfig{
+			Level: logrus.InfoLevel.String(),
+		},
+		Tracing: TracingConfig{
+			Exporter:     tracing.ExporterNone,
+			ServiceName:  "video-hint",
+			OTLPEndpoint: "localhost:4318",
+			SampleRatio:  1,
+		},
+	}
+}
+
+var sslModes = map[string]bool{
+	"":            true,
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Validate reports the first invalid setting
+func (c *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		return fmt.Errorf("http.addr must be a host:port, got %q: %w", c.HTTP.Addr, err)
+	}
+	if (c.HTTP.TLSCertFile == "") != (c.HTTP.TLSKeyFile == "") {
+		return fmt.Errorf("http.tls_cert_file and http.tls_key_file must be set together")
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+		{"db.connect_timeout", c.DB.ConnectTimeout},
+		{"db.pool.max_conn_idle_time", c.DB.Pool.MaxConnIdleTime},
+		{"db.pool.max_conn_lifetime", c.DB.Pool.MaxConnLifetime},
+	} {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be a positive duration, got %v", d.name, d.value)
+		}
+	}
+	if c.HTTP.ShutdownDelay < 0 {
+		return fmt.Errorf("http.shutdown_delay must not be negative, got %v", c.HTTP.ShutdownDelay)
+	}
+	if c.DB.StatementTimeout < 0 {
+		return fmt.Errorf("db.statement_timeout must not be negative, got %v", c.DB.StatementTimeout)
+	}
+
+	if _, err := storage.ParseDriver(c.DB.Driver); err != nil {
+		return fmt.Errorf("db.driver: %w", err)
+	}
+	if !sslModes[c.DB.SSLMode] {
+		return fmt.Errorf("db.sslmode must be one of disable, allow, prefer, require, verify-ca or verify-full, got %q", c.DB.SSLMode)
+	}
+	if (c.DB.SSLCert == "") != (c.DB.SSLKey == "") {
+		return fmt.Errorf("db.sslcert and db.sslkey must be set together")
+	}
+	if c.DB.TimeZone != "" {
+		if _, err := time.LoadLocation(c.DB.TimeZone); err != nil {
+			return fmt.Errorf("db.timezone must be a time zone name, got %q: %w", c.DB.TimeZone, err)
+		}
+	}
+	if err := c.StorageConfig().ConnString.Validate(); err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	if c.DB.Pool.MaxConns <= 0 || c.DB.Pool.MinConns <= 0 {
+		return fmt.Errorf("db.pool.max_conns and db.pool.min_conns must be positive")
+	}
+	if c.DB.Pool.MinConns > c.DB.Pool.MaxConns {
+		return fmt.Errorf("db.pool.min_conns (%d) must not exceed db.pool.max_conns (%d)", c.DB.Pool.MinConns, c.DB.Pool.MaxConns)
+	}
+
+	if c.API.DefaultPageSize <= 0 || c.API.MaxPageSize <= 0 {
+		return fmt.Errorf("api.default_page_size and api.max_page_size must be positive")
+	}
+	if c.API.DefaultPageSize > c.API.MaxPageSize {
+		return fmt.Errorf("api.default_page_size (%d) must not exceed api.max_page_size (%d)", c.API.DefaultPageSize, c.API.MaxPageSize)
+	}
+	if c.API.SimilarityThreshold <= 0 || c.API.SimilarityThreshold > 1 {
+		return fmt.Errorf("api.similarity_threshold must be a number in (0, 1], got %v", c.API.SimilarityThreshold)
+	}
+
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if _, _, err := net.SplitHostPort(c.Tracing.OTLPEndpoint); err != nil {
+			return fmt.Errorf("tracing.otlp_endpoint must be a host:port, got %q: %w", c.Tracing.OTLPEndpoint, err)
+		}
+	default:
+		return fmt.Errorf("tracing.exporter must be one of none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.ServiceName == "" {
+		return fmt.Errorf("tracing.service_name must not be empty")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be a number in [0, 1], got %v", c.Tracing.SampleRatio)
+	}
+	return nil
+}
+
+// LogLevel is the parsed log level, Validate makes sure it parses
+func (c *Config) LogLevel() logrus.Level {
+	level, err := logrus.ParseLevel(c.Log.Level)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+	return level
+}
+
+// StorageConfig maps the DB settings onto the storage ones
+func (c *Config) StorageConfig() *storage.Config {
+	return &storage.Config{
+		Driver: storage.Driver(c.DB.Driver),
+		ConnString: &storage.ConnString{
+			URL:         c.DB.URL,
+			Host:        c.DB.Host,
+			Port:        c.DB.Port,
+			User:        c.DB.User,
+			Password:    c.DB.Password,
+			DBName:      c.DB.Name,
+			SSLMode:     c.DB.SSLMode,
+			SSLRootCert: c.DB.SSLRootCert,
+			SSLCert:     c.DB.SSLCert,
+			SSLKey:      c.DB.SSLKey,
+			TimeZone:    c.DB.TimeZone,
+		},
+		Pool: storage.PoolConfig{
+			MaxConns:        int32(c.DB.Pool.MaxConns),
+			MinConns:        int32(c.DB.Pool.MinConns),
+			MaxConnIdleTime: c.DB.Pool.MaxConnIdleTime,
+			MaxConnLifetime: c.DB.Pool.MaxConnLifetime,
+		},
+		ConnectTimeout:   c.DB.ConnectTimeout,
+		StatementTimeout: c.DB.StatementTimeout,
+	}
+}
+
+// TracingConfig maps the tracing settings onto the tracing ones
+func (c *Config) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:     c.Tracing.Exporter,
+		ServiceName:  c.Tracing.ServiceName,
+		OTLPEndpoint: c.Tracing.OTLPEndpoint,
+		OTLPInsecure: c.Tracing.OTLPInsecure,
+		SampleRatio:  c.Tracing.SampleRatio,
+	}
+}
+
+// PageLimits maps the API settings onto the service ones
+func (c *Config) PageLimits() service.PageLimits {
+	return service.PageLimits{
+		Default: c.API.DefaultPageSize,
+		Max:     c.API.MaxPageSize,
+	}
+}
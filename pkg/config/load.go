@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FileVarName names the config file, the -config flag does the same
+	FileVarName  = "CONFIG_FILE"
+	fileFlagName = "config"
+)
+
+// setting binds a config field to its env var and flag, secrets have no flag
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	setter setter
+}
+
+// setter parses a value into a config field
+type setter struct {
+	set    func(c *Config, val string) error
+	isBool bool
+}
+
+type flagValue struct {
+	val    string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.val
+}
+
+func (v *flagValue) Set(val string) error {
+	v.val = val
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+var settings = []setting{
+	{"LISTEN_ADDR", "listen-addr", "HTTP listen address", stringSetter(func(c *Config) *string { return &c.HTTP.Addr })},
+	{"TLS_CERT_FILE", "tls-cert-file", "HTTPS certificate file", stringSetter(func(c *Config) *string { return &c.HTTP.TLSCertFile })},
+	{"TLS_KEY_FILE", "tls-key-file", "HTTPS private key file", stringSetter(func(c *Config) *string { return &c.HTTP.TLSKeyFile })},
+	{"HTTP_READ_TIMEOUT", "http-read-timeout", "timeout of reading a request", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ReadTimeout })},
+	{"HTTP_WRITE_TIMEOUT", "http-write-timeout", "timeout of writing a response", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.WriteTimeout })},
+	{"HTTP_IDLE_TIMEOUT", "http-idle-timeout", "timeout of an idle keep-alive connection", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.IdleTimeout })},
+	{"HTTP_SHUTDOWN_DELAY", "http-shutdown-delay", "time the listener stays open after readiness turns unhealthy on shutdown", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ShutdownDelay })},
+	{"HTTP_SHUTDOWN_TIMEOUT", "http-shutdown-timeout", "time the in-flight requests get to finish on shutdown", durationSetter(func(c *Config) *time.Duration { return &c.HTTP.ShutdownTimeout })},
+
+	{"DB_DRIVER", "db-driver", "DB driver: pgx or gorm", stringSetter(func(c *Config) *string { return &c.DB.Driver })},
+	{"DATABASE_URL", "", "", stringSetter(func(c *Config) *string { return &c.DB.URL })},
+	{"DB_HOST", "db-host", "DB host", stringSetter(func(c *Config) *string { return &c.DB.Host })},
+	{"DB_PORT", "db-port", "DB port", stringSetter(func(c *Config) *string { return &c.DB.Port })},
+	{"DB_USER", "db-user", "DB user", stringSetter(func(c *Config) *string { return &c.DB.User })},
+	{"DB_PASSWORD", "", "", stringSetter(func(c *Config) *string { return &c.DB.Password })},
+	{"DB_NAME", "db-name", "DB name", stringSetter(func(c *Config) *string { return &c.DB.Name })},
+	{"DB_SSLMODE", "db-sslmode", "DB sslmode", stringSetter(func(c *Config) *string { return &c.DB.SSLMode })},
+	{"DB_SSLROOTCERT", "db-sslrootcert", "CA file checking the DB server certificate", stringSetter(func(c *Config) *string { return &c.DB.SSLRootCert })},
+	{"DB_SSLCERT", "db-sslcert", "DB client certificate file", stringSetter(func(c *Config) *string { return &c.DB.SSLCert })},
+	{"DB_SSLKEY", "db-sslkey", "DB client private key file", stringSetter(func(c *Config) *string { return &c.DB.SSLKey })},
+	{"DB_TIMEZONE", "db-timezone", "time zone of the DB sessions", stringSetter(func(c *Config) *string { return &c.DB.TimeZone })},
+	{"DB_CONNECT_TIMEOUT", "db-connect-timeout", "timeout of connecting to the DB", durationSetter(func(c *Config) *time.Duration { return &c.DB.ConnectTimeout })},
+	{"DB_STATEMENT_TIMEOUT", "db-statement-timeout", "timeout of a DB statement, 0 keeps the server setting", durationSetter(func(c *Config) *time.Duration { return &c.DB.StatementTimeout })},
+	{"DB_AUTO_MIGRATE", "db-auto-migrate", "apply pending migrations on startup", boolSetter(func(c *Config) *bool { return &c.DB.AutoMigrate })},
+	{"DB_MAX_CONNS", "db-max-conns", "max DB pool size", intSetter(func(c *Config) *int { return &c.DB.Pool.MaxConns })},
+	{"DB_MIN_CONNS", "db-min-conns", "min DB pool size", intSetter(func(c *Config) *int { return &c.DB.Pool.MinConns })},
+	{"DB_MAX_CONN_IDLE_TIME", "db-max-conn-idle-time", "time an idle DB connection is kept", durationSetter(func(c *Config) *time.Duration { return &c.DB.Pool.MaxConnIdleTime })},
+	{"DB_MAX_CONN_LIFETIME", "db-max-conn-lifetime", "time a DB connection is reused", durationSetter(func(c *Config) *time.Duration { return &c.DB.Pool.MaxConnLifetime })},
+
+	{"DEFAULT_PAGE_SIZE", "default-page-size", "page size used if none is requested", intSetter(func(c *Config) *int { return &c.API.DefaultPageSize })},
+	{"MAX_PAGE_SIZE", "max-page-size", "max page size", intSetter(func(c *Config) *int { return &c.API.MaxPageSize })},
+	{"SIMILARITY_THRESHOLD", "similarity-threshold", "min similarity of fuzzy search results", floatSetter(func(c *Config) *float64 { return &c.API.SimilarityThreshold })},
+
+	{"LOG_LEVEL", "log-level", "log level", stringSetter(func(c *Config) *string { return &c.Log.Level })},
+
+	{"TRACING_EXPORTER", "tracing-exporter", "trace exporter: none, stdout or otlp", stringSetter(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"TRACING_SERVICE_NAME", "tracing-service-name", "service name of the spans", stringSetter(func(c *Config) *string { return &c.Tracing.ServiceName })},
+	{"TRACING_OTLP_ENDPOINT", "tracing-otlp-endpoint", "host:port of the OTLP collector", stringSetter(func(c *Config) *string { return &c.Tracing.OTLPEndpoint })},
+	{"TRACING_OTLP_INSECURE", "tracing-otlp-insecure", "send the spans to the OTLP collector over plain HTTP", boolSetter(func(c *Config) *bool { return &c.Tracing.OTLPInsecure })},
+	{"TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "share of the traces started by the service that are sampled", floatSetter(func(c *Config) *float64 { return &c.Tracing.SampleRatio })},
+}
+
+// Load builds the config from the defaults, flags, the file and env vars, each overriding the previous
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	file := fs.String(fileFlagName, "", "YAML or TOML config file")
+	flagVals := make(map[string]*flagValue, len(settings))
+	for _, s := range settings {
+		if s.flag != "" {
+			flagVals[s.flag] = &flagValue{isBool: s.setter.isBool}
+			fs.Var(flagVals[s.flag], s.flag, s.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if err == nil && s.flag == f.Name {
+				err = setFrom(c, s, flagVals[s.flag].val, "flag -"+s.flag)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok := lookupEnv(FileVarName); ok {
+		*file = val
+	}
+	if *file != "" {
+		if err := loadFile(c, *file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if val, ok := lookupEnv(s.env); ok {
+			if err := setFrom(c, s, val, "variable "+s.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return c, nil
+}
+
+// Usage describes the flags and the env vars
+func Usage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  -%s\t%s, or %s\n", fileFlagName, "YAML or TOML config file", FileVarName)
+	for _, s := range settings {
+		if s.flag == "" {
+			fmt.Fprintf(&b, "  %s only\n", s.env)
+			continue
+		}
+		fmt.Fprintf(&b, "  -%s\t%s, or %s\n", s.flag, s.usage, s.env)
+	}
+	return b.String()
+}
+
+func setFrom(c *Config, s setting, val, source string) error {
+	if err := s.setter.set(c, val); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+// loadFile overlays a YAML or TOML file, unknown keys are rejected so typos do not go unnoticed
+func loadFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the config file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return fmt.Errorf("failed to parse the config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.NewDecoder(bytes.NewReader(data)).Decode(c)
+		if err != nil {
+			return fmt.Errorf("failed to parse the config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown settings in the config file %s: %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("the config file must be .yaml, .yml or .toml, got %q", ext)
+	}
+	return nil
+}
+
+func stringSetter(field func(c *Config) *string) setter {
+	return setter{set: func(c *Config, val string) error {
+		*field(c) = val
+		return nil
+	}}
+}
+
+func intSetter(field func(c *Config) *int) setter {
+	return setter{set: func(c *Config, val string) error {
+		n, err := strconv.ParseInt(val, 10, 32)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", val)
+		}
+		*field(c) = int(n)
+		return nil
+	}}
+}
+
+func floatSetter(field func(c *Config) *float64) setter {
+	return setter{set: func(c *Config, val string) error {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", val)
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+func boolSetter(field func(c *Config) *bool) setter {
+	return setter{set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("must be a boolean, got %q", val)
+		}
+		*field(c) = b
+		return nil
+	}, isBool: true}
+}
+
+func durationSetter(field func(c *Config) *time.Duration) setter {
+	return setter{set: func(c *Config, val string) error {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("must be a duration, got %q", val)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
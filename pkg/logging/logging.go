@@ -0,0 +1,129 @@
+// Package logging threads the logger and the request ID through the context
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RequestIDHeader carries the request ID, the one set by the caller is kept
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDField names the request ID in the log lines
+	RequestIDField = "request_id"
+)
+
+// requestIDRe bounds the request IDs accepted from callers, so they cannot forge log lines
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type ctxKey int
+
+const (
+	loggerKey ctxKey = iota
+	requestIDKey
+)
+
+// New creates the JSON logger of the service
+func New(out io.Writer, level logrus.Level) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(out)
+	logger.SetFormatter(new(logrus.JSONFormatter))
+	logger.SetLevel(level)
+	return logger
+}
+
+// WithLogger returns a context carrying the logger entry of a request
+func WithLogger(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey, entry)
+}
+
+// FromContext returns the logger entry of the request or the standard logger
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID of the context, it is empty outside of requests
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Middleware tags every request with an ID and writes an access log line
+func Middleware(logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if !requestIDRe.MatchString(id) {
+				id = newRequestID()
+				r.Header.Set(RequestIDHeader, id)
+			}
+			w.Header().Set(RequestIDHeader, id)
+			entry := logger.WithField(RequestIDField, id)
+			ctx := WithLogger(WithRequestID(r.Context(), id), entry)
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			route := ""
+			if cur := mux.CurrentRoute(r); cur != nil {
+				route, _ = cur.GetPathTemplate()
+			}
+			entry.WithFields(logrus.Fields{
+				"method":      r.Method,
+				"route":       route,
+				"path":        r.URL.Path,
+				"status":      rec.status,
+				"bytes":       rec.bytes,
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			}).Info("request served")
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// a time based ID still tells the requests apart
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// responseRecorder remembers the status code and counts the body bytes written
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		Name       string
+		RequestID  string
+		ExpectKept bool
+	}{
+		{
+			Name:       "caller ID",
+			RequestID:  "3f2a-41c9.b7",
+			ExpectKept: true,
+		},
+		{
+			Name: "no ID",
+		},
+		{
+			Name:      "malformed ID",
+			RequestID: "forged\n{\"level\":\"error\"}",
+		},
+		{
+			Name:      "too long ID",
+			RequestID: strings.Repeat("a", 129),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := New(&out, logrus.InfoLevel)
+			var handlerID string
+			r := mux.NewRouter()
+			r.Use(Middleware(logger))
+			r.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+				handlerID = RequestID(r.Context())
+				FromContext(r.Context()).Info("handling")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, "{}")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/videos/1", nil)
+			if tc.RequestID != "" {
+				req.Header.Set(RequestIDHeader, tc.RequestID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != handlerID {
+				t.Fatalf("expected the response and the handler to get the same ID, got: %q and %q", id, handlerID)
+			}
+			if tc.ExpectKept != (id == tc.RequestID) {
+				t.Errorf("expected the caller ID %q to be kept: %v, got: %q", tc.RequestID, tc.ExpectKept, id)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("expected the handler and the access log lines, got: %q", out.String())
+			}
+			for _, line := range lines {
+				var fields map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &fields); err != nil {
+					t.Fatalf("failed to parse the log line %q: %v", line, err)
+				}
+				if fields[RequestIDField] != id {
+					t.Errorf("expected the log line to carry the request ID %q, got: %v", id, fields[RequestIDField])
+				}
+			}
+			var access map[string]interface{}
+			json.Unmarshal([]byte(lines[1]), &access)
+			expected := map[string]interface{}{
+				"method": "POST",
+				"route":  "/videos/{id}",
+				"status": float64(http.StatusCreated),
+				"bytes":  float64(2),
+			}
+			for key, val := range expected {
+				if access[key] != val {
+					t.Errorf("expected the access log %s: %v, got: %v", key, val, access[key])
+				}
+			}
+			if _, ok := access["duration_ms"]; !ok {
+				t.Errorf("expected the access log to carry the duration")
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if entry := FromContext(context.Background()); entry.Logger != logrus.StandardLogger() {
+		t.Errorf("expected the standard logger outside of requests")
+	}
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("expected no request ID outside of requests, got: %q", id)
+	}
+}
@@ -0,0 +1,169 @@
+// Package metrics keeps its collectors on a dedicated registry, so tests gather only their own
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const namespace = "video_hint"
+
+// Metrics implements storage.QueryObserver and service.SearchObserver
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	searchResults *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+}
+
+// New registers the collectors, the Go runtime and the process ones included, on a new registry
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests served by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time of serving HTTP requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		searchResults: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "search",
+			Name:      "results",
+			Help:      "Number of videos on a search results page by search mode.",
+			Buckets:   []float64{0, 1, 5, 10, 20, 50, 100},
+		}, []string{"mode"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "query_duration_seconds",
+			Help:      "Time of storage calls by method, backend and outcome.",
+			Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"method", "backend", "outcome"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.searchResults,
+		m.queryDuration,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Middleware counts and times the requests by route template, not to make a series per video
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		route := "unknown"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		code := strconv.Itoa(rec.status)
+		m.httpRequests.WithLabelValues(route, r.Method, code).Inc()
+		m.httpDuration.WithLabelValues(route, r.Method, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code written, handlers writing the body alone send 200
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// ObserveSearch records the size of a search results page
+func (m *Metrics) ObserveSearch(mode string, results int) {
+	m.searchResults.WithLabelValues(mode).Observe(float64(results))
+}
+
+// ObserveQuery records the time of a storage call
+func (m *Metrics) ObserveQuery(backend storage.Driver, method string, d time.Duration, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.queryDuration.WithLabelValues(method, string(backend), outcome).Observe(d.Seconds())
+}
+
+// RegisterPool exposes the connection pool stats of db, they are read on every scrape
+func (m *Metrics) RegisterPool(db storage.DB) {
+	m.Registry.MustRegister(&poolCollector{db: db})
+}
+
+var (
+	poolMaxConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "max_conns"),
+		"Max number of connections the pool opens, 0 means no limit.", nil, nil)
+	poolTotalConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "total_conns"),
+		"Number of open connections.", nil, nil)
+	poolAcquiredConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "acquired_conns"),
+		"Number of connections serving queries.", nil, nil)
+	poolIdleConns = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "idle_conns"),
+		"Number of idle connections.", nil, nil)
+	poolWaitCount = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "wait_count_total"),
+		"Number of queries that waited for a connection.", nil, nil)
+	poolWaitDuration = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "wait_duration_seconds_total"),
+		"Time queries spent waiting for a connection, not exported by the pgx backend.", nil, nil)
+)
+
+// poolCollector reads the pool stats once per scrape, so the gauges are consistent
+type poolCollector struct {
+	db storage.DB
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{poolMaxConns, poolTotalConns, poolAcquiredConns, poolIdleConns, poolWaitCount, poolWaitDuration} {
+		ch <- desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stats.MaxConns))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stats.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(poolWaitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	if stats.HasWaitDuration {
+		ch <- prometheus.MustNewConstMetric(poolWaitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	}
+}
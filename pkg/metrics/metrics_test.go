@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}).Methods("GET")
+
+	for _, path := range []string{"/videos/1", "/videos/2", "/videos/0"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	cases := []struct {
+		Code     string
+		Expected float64
+	}{
+		{Code: "200", Expected: 2},
+		{Code: "404", Expected: 1},
+	}
+	for _, tc := range cases {
+		got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/videos/{id}", "GET", tc.Code))
+		if got != tc.Expected {
+			t.Errorf("expected %v requests with code %s, got: %v", tc.Expected, tc.Code, got)
+		}
+	}
+	if n := testutil.CollectAndCount(m.httpDuration); n != 2 {
+		t.Errorf("expected a latency histogram per route and status, got %d", n)
+	}
+}
+
+func TestObservers(t *testing.T) {
+	m := New()
+	m.ObserveSearch("substring", 20)
+	m.ObserveSearch("fuzzy", 3)
+	m.ObserveQuery(storage.DriverPGX, "GetVideo", time.Millisecond, nil)
+	m.ObserveQuery(storage.DriverGorm, "GetVideo", time.Millisecond, storage.ErrNotFound)
+
+	if n := testutil.CollectAndCount(m.searchResults); n != 2 {
+		t.Errorf("expected a histogram per search mode, got %d", n)
+	}
+	expected := `
+# HELP video_hint_storage_query_duration_seconds Time of storage calls by method, backend and outcome.
+# TYPE video_hint_storage_query_duration_seconds histogram
+`
+	for _, outcome := range []struct{ backend, outcome string }{{"gorm", "error"}, {"pgx", "ok"}} {
+		for _, le := range []string{"0.001", "0.0025", "0.005", "0.01", "0.025", "0.05", "0.1", "0.25", "0.5", "1", "2.5", "5", "+Inf"} {
+			expected += fmt.Sprintf("video_hint_storage_query_duration_seconds_bucket{backend=%q,method=\"GetVideo\",outcome=%q,le=%q} 1\n", outcome.backend, outcome.outcome, le)
+		}
+		expected += fmt.Sprintf("video_hint_storage_query_duration_seconds_sum{backend=%q,method=\"GetVideo\",outcome=%q} 0.001\n", outcome.backend, outcome.outcome)
+		expected += fmt.Sprintf("video_hint_storage_query_duration_seconds_count{backend=%q,method=\"GetVideo\",outcome=%q} 1\n", outcome.backend, outcome.outcome)
+	}
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(expected), "video_hint_storage_query_duration_seconds"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterPool(t *testing.T) {
+	m := New()
+	m.RegisterPool(&poolDBMock{stats: storage.PoolStats{
+		MaxConns:        10,
+		TotalConns:      4,
+		AcquiredConns:   3,
+		IdleConns:       1,
+		WaitCount:       7,
+		WaitDuration:    time.Second * 2,
+		HasWaitDuration: true,
+	}})
+	expected := `
+# HELP video_hint_db_pool_acquired_conns Number of connections serving queries.
+# TYPE video_hint_db_pool_acquired_conns gauge
+video_hint_db_pool_acquired_conns 3
+# HELP video_hint_db_pool_idle_conns Number of idle connections.
+# TYPE video_hint_db_pool_idle_conns gauge
+video_hint_db_pool_idle_conns 1
+# HELP video_hint_db_pool_wait_count_total Number of queries that waited for a connection.
+# TYPE video_hint_db_pool_wait_count_total counter
+video_hint_db_pool_wait_count_total 7
+# HELP video_hint_db_pool_wait_duration_seconds_total Time queries spent waiting for a connection, not exported by the pgx backend.
+# TYPE video_hint_db_pool_wait_duration_seconds_total counter
+video_hint_db_pool_wait_duration_seconds_total 2
+`
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(expected),
+		"video_hint_db_pool_acquired_conns", "video_hint_db_pool_idle_conns",
+		"video_hint_db_pool_wait_count_total", "video_hint_db_pool_wait_duration_seconds_total",
+	); err != nil {
+		t.Error(err)
+	}
+
+	m = New()
+	m.RegisterPool(&poolDBMock{stats: storage.PoolStats{WaitCount: 7}})
+	if n, err := testutil.GatherAndCount(m.Registry, "video_hint_db_pool_wait_duration_seconds_total"); err != nil || n != 0 {
+		t.Errorf("expected no wait duration of a backend not tracking it, got %d series: %v", n, err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveSearch("substring", 1)
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code: %d, got: %d", http.StatusOK, w.Code)
+	}
+	for _, name := range []string{"video_hint_search_results_count", "go_goroutines"} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("expected %s to be exposed", name)
+		}
+	}
+}
+
+type poolDBMock struct {
+	storage.DB
+
+	stats storage.PoolStats
+}
+
+func (db *poolDBMock) Stats() storage.PoolStats {
+	return db.stats
+}
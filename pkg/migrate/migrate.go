@@ -0,0 +1,250 @@
+// Package migrate applies the embedded migrations compatibly with golang-migrate
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Table is the name of the table tracking the applied version
+const Table = "schema_migrations"
+
+// ChecksumTable keeps the checksums of the migrations applied by the service
+const ChecksumTable = "schema_migrations_checksums"
+
+// schema is the schema golang-migrate keeps its table in by default
+const schema = "public"
+
+// advisoryLockIDSalt is the salt golang-migrate mixes into its advisory lock id
+const advisoryLockIDSalt uint32 = 1486364155
+
+const pgCodeUndefinedTable = "42P01"
+
+var ErrDirty = fmt.Errorf("the DB is dirty, a migration failed half way")
+
+// Migrator applies migrations over a single connection holding the advisory lock
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []*Migration
+	lockID     int64
+	logger     Logger
+}
+
+// Logger is told about every applied migration
+type Logger interface {
+	Infof(format string, args ...interface{})
+}
+
+// Status is the applied version, NilVersion if none, and the list of migrations
+type Status struct {
+	Version    int64
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+type MigrationStatus struct {
+	*Migration
+	Applied bool
+}
+
+// New creates a migrator, logger may be nil
+func New(conn *pgx.Conn, migrations []*Migration, logger Logger) *Migrator {
+	return &Migrator{
+		conn:       conn,
+		migrations: migrations,
+		lockID:     advisoryLockID(conn.Config().Database),
+		logger:     logger,
+	}
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(current int64) ([]step, error) {
+		return planUp(m.migrations, current)
+	})
+}
+
+// Down rolls back the last n migrations
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.run(ctx, func(current int64) ([]step, error) {
+		return planDown(m.migrations, current, n)
+	})
+}
+
+// Goto migrates up or down to the given version
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	return m.run(ctx, func(current int64) ([]step, error) {
+		return planGoto(m.migrations, current, version)
+	})
+}
+
+// Status reads the applied version, it does not create the version table
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	version, dirty, err := m.version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{
+		Version:    version,
+		Dirty:      dirty,
+		Migrations: make([]MigrationStatus, 0, len(m.migrations)),
+	}
+	for _, mig := range m.migrations {
+		s.Migrations = append(s.Migrations, MigrationStatus{
+			Migration: mig,
+			Applied:   version != NilVersion && mig.Version <= version,
+		})
+	}
+	return s, nil
+}
+
+// run plans the steps under the advisory lock, so concurrent runners apply each migration once
+func (m *Migrator) run(ctx context.Context, plan func(current int64) ([]step, error)) (err error) {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, m.lockID); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer func() {
+		// the lock has to be released even if ctx is already done
+		if _, unlockErr := m.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, m.lockID); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release the migration lock: %w", unlockErr)
+		}
+	}()
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	current, dirty, err := m.version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d", ErrDirty, current)
+	}
+	steps, err := plan(current)
+	if err != nil {
+		return err
+	}
+	for _, s := range steps {
+		if err := m.apply(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs a migration keeping the version dirty until it succeeds, like golang-migrate
+func (m *Migrator) apply(ctx context.Context, s step) error {
+	direction, body := "down", s.Migration.Down
+	if s.Up {
+		direction, body = "up", s.Migration.Up
+	}
+	err := m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return setVersion(ctx, tx, s.Target, true)
+	})
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, body); err != nil {
+		return fmt.Errorf("failed to apply migration %s (%s): %w", s.Migration, direction, err)
+	}
+	err = m.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if err := setVersion(ctx, tx, s.Target, false); err != nil {
+			return err
+		}
+		return recordChecksum(ctx, tx, s)
+	})
+	if err != nil {
+		return err
+	}
+	if m.logger != nil {
+		m.logger.Infof("applied migration %s (%s)", s.Migration, direction)
+	}
+	return nil
+}
+
+// exec sends a no-transaction script statement by statement, other scripts at once
+func (m *Migrator) exec(ctx context.Context, script string) error {
+	if !noTransaction(script) {
+		_, err := m.conn.Exec(ctx, script)
+		return err
+	}
+	for _, stmt := range splitStatements(script) {
+		if _, err := m.conn.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+Table+` (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create the %s table: %w", Table, err)
+	}
+	_, err = m.conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+ChecksumTable+` (
+		version bigint NOT NULL PRIMARY KEY,
+		checksum text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create the %s table: %w", ChecksumTable, err)
+	}
+	return nil
+}
+
+func (m *Migrator) version(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := m.conn.QueryRow(ctx, `SELECT version, dirty FROM `+Table+` LIMIT 1`).Scan(&version, &dirty)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return NilVersion, false, nil
+	case errors.As(err, &pgErr) && pgErr.Code == pgCodeUndefinedTable:
+		return NilVersion, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("failed to read the applied version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// setVersion replaces the only row of the version table, a dirty NilVersion is kept
+func setVersion(ctx context.Context, tx pgx.Tx, version int64, dirty bool) error {
+	if _, err := tx.Exec(ctx, `TRUNCATE `+Table); err != nil {
+		return fmt.Errorf("failed to record version %d: %w", version, err)
+	}
+	if version == NilVersion && !dirty {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO `+Table+` (version, dirty) VALUES ($1, $2)`, version, dirty); err != nil {
+		return fmt.Errorf("failed to record version %d: %w", version, err)
+	}
+	return nil
+}
+
+// recordChecksum keeps the checksum of an applied migration and forgets a rolled back one
+func recordChecksum(ctx context.Context, tx pgx.Tx, s step) error {
+	var err error
+	if s.Up {
+		_, err = tx.Exec(ctx, `INSERT INTO `+ChecksumTable+` (version, checksum) VALUES ($1, $2)
+			ON CONFLICT (version) DO UPDATE SET checksum = EXCLUDED.checksum, applied_at = now()`,
+			s.Migration.Version, s.Migration.Checksum)
+	} else {
+		_, err = tx.Exec(ctx, `DELETE FROM `+ChecksumTable+` WHERE version = $1`, s.Migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record the checksum of migration %s: %w", s.Migration, err)
+	}
+	return nil
+}
+
+// advisoryLockID derives the lock id the way golang-migrate does, so the two exclude each other
+func advisoryLockID(dbName string) int64 {
+	sum := crc32.ChecksumIEEE([]byte(strings.Join([]string{schema, Table, dbName}, "\x00")))
+	return int64(sum * advisoryLockIDSalt)
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import "fmt"
+
+// NilVersion is the version of a DB no migration was applied to
+const NilVersion int64 = -1
+
+// step runs one migration, Target is the version recorded once it succeeds
+type step struct {
+	Migration *Migration
+	Up        bool
+	Target    int64
+}
+
+// planUp applies every migration newer than the current version
+func planUp(migrations []*Migration, current int64) ([]step, error) {
+	from, err := position(migrations, current)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]step, 0, len(migrations)-from-1)
+	for _, m := range migrations[from+1:] {
+		steps = append(steps, step{Migration: m, Up: true, Target: m.Version})
+	}
+	return steps, nil
+}
+
+// planDown rolls back n migrations, or all of them if there are fewer
+func planDown(migrations []*Migration, current int64, n int) ([]step, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("the number of migrations to roll back must be positive, got %d", n)
+	}
+	from, err := position(migrations, current)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]step, 0, n)
+	for i := from; i >= 0 && len(steps) < n; i-- {
+		m := migrations[i]
+		if m.Down == "" {
+			return nil, fmt.Errorf("migration %s has no down file", m)
+		}
+		target := NilVersion
+		if i > 0 {
+			target = migrations[i-1].Version
+		}
+		steps = append(steps, step{Migration: m, Up: false, Target: target})
+	}
+	return steps, nil
+}
+
+// planGoto moves the schema up or down to the given version
+func planGoto(migrations []*Migration, current int64, version int64) ([]step, error) {
+	if version == NilVersion {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+	to, err := position(migrations, version)
+	if err != nil {
+		return nil, err
+	}
+	from, err := position(migrations, current)
+	if err != nil {
+		return nil, err
+	}
+	if to >= from {
+		return planUp(migrations[:to+1], current)
+	}
+	return planDown(migrations, current, from-to)
+}
+
+// position finds the index of the migration of the version, NilVersion is before the first one
+func position(migrations []*Migration, version int64) (int, error) {
+	if version == NilVersion {
+		return -1, nil
+	}
+	for i, m := range migrations {
+		if m.Version == version {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown migration version %d", version)
+}
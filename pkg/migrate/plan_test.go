@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	ms := []*Migration{
+		{Version: 1, Name: "init", Up: "up", Down: "down"},
+		{Version: 2, Name: "add_indexes", Up: "up", Down: "down"},
+		{Version: 5, Name: "add_trgm", Up: "up", Down: "down"},
+	}
+	up := func(v int64) string { return fmt.Sprintf("up %d", v) }
+	down := func(v, target int64) string { return fmt.Sprintf("down %d to %d", v, target) }
+
+	cases := []struct {
+		Name          string
+		Plan          func() ([]step, error)
+		ExpectedSteps []string
+		ExpectErr     bool
+	}{
+		{
+			Name:          "up from scratch",
+			Plan:          func() ([]step, error) { return planUp(ms, NilVersion) },
+			ExpectedSteps: []string{up(1), up(2), up(5)},
+		},
+		{
+			Name:          "up from the middle",
+			Plan:          func() ([]step, error) { return planUp(ms, 2) },
+			ExpectedSteps: []string{up(5)},
+		},
+		{
+			Name:          "up to date",
+			Plan:          func() ([]step, error) { return planUp(ms, 5) },
+			ExpectedSteps: []string{},
+		},
+		{
+			Name:      "up from an unknown version",
+			Plan:      func() ([]step, error) { return planUp(ms, 3) },
+			ExpectErr: true,
+		},
+		{
+			Name:          "down one",
+			Plan:          func() ([]step, error) { return planDown(ms, 5, 1) },
+			ExpectedSteps: []string{down(5, 2)},
+		},
+		{
+			Name:          "down past the first",
+			Plan:          func() ([]step, error) { return planDown(ms, 2, 10) },
+			ExpectedSteps: []string{down(2, 1), down(1, NilVersion)},
+		},
+		{
+			Name:          "down on a clean DB",
+			Plan:          func() ([]step, error) { return planDown(ms, NilVersion, 1) },
+			ExpectedSteps: []string{},
+		},
+		{
+			Name:      "down zero",
+			Plan:      func() ([]step, error) { return planDown(ms, 5, 0) },
+			ExpectErr: true,
+		},
+		{
+			Name: "down without a down file",
+			Plan: func() ([]step, error) {
+				return planDown([]*Migration{{Version: 1, Name: "init", Up: "up"}}, 1, 1)
+			},
+			ExpectErr: true,
+		},
+		{
+			Name:          "goto a later version",
+			Plan:          func() ([]step, error) { return planGoto(ms, 1, 2) },
+			ExpectedSteps: []string{up(2)},
+		},
+		{
+			Name:          "goto an earlier version",
+			Plan:          func() ([]step, error) { return planGoto(ms, 5, 1) },
+			ExpectedSteps: []string{down(5, 2), down(2, 1)},
+		},
+		{
+			Name:          "goto the current version",
+			Plan:          func() ([]step, error) { return planGoto(ms, 2, 2) },
+			ExpectedSteps: []string{},
+		},
+		{
+			Name:      "goto an unknown version",
+			Plan:      func() ([]step, error) { return planGoto(ms, 2, 4) },
+			ExpectErr: true,
+		},
+		{
+			Name:      "goto nil version",
+			Plan:      func() ([]step, error) { return planGoto(ms, 2, NilVersion) },
+			ExpectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			steps, err := tc.Plan()
+			if tc.ExpectErr {
+				if err == nil {
+					t.Errorf("expected an error, got steps: %v", steps)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			got := make([]string, 0, len(steps))
+			for _, s := range steps {
+				if s.Up {
+					got = append(got, up(s.Migration.Version))
+				} else {
+					got = append(got, down(s.Migration.Version, s.Target))
+				}
+			}
+			if len(got) != len(tc.ExpectedSteps) {
+				t.Errorf("expected steps: %v, got: %v", tc.ExpectedSteps, got)
+				return
+			}
+			for i := range got {
+				if got[i] != tc.ExpectedSteps[i] {
+					t.Errorf("expected steps: %v, got: %v", tc.ExpectedSteps, got)
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		Name             string
+		Files            fstest.MapFS
+		ExpectedVersions []int64
+		ExpectErr        bool
+	}{
+		{
+			Name: "ordered by version",
+			Files: fstest.MapFS{
+				"10_add_comments.up.sql":   {Data: []byte("up")},
+				"10_add_comments.down.sql": {Data: []byte("down")},
+				"2_add_indexes.up.sql":     {Data: []byte("up")},
+				"1_init.up.sql":            {Data: []byte("up")},
+				"1_init.down.sql":          {Data: []byte("down")},
+			},
+			ExpectedVersions: []int64{1, 2, 10},
+		},
+		{
+			Name: "other files are skipped",
+			Files: fstest.MapFS{
+				"1_init.up.sql":   {Data: []byte("up")},
+				"migrations.go":   {Data: []byte("package migrations")},
+				"README.md":       {Data: []byte("readme")},
+				"old/2_x.up.sql":  {Data: []byte("up")},
+				"1_init.down.sql": {Data: []byte("down")},
+			},
+			ExpectedVersions: []int64{1},
+		},
+		{
+			Name: "no up file",
+			Files: fstest.MapFS{
+				"1_init.down.sql": {Data: []byte("down")},
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "duplicate version",
+			Files: fstest.MapFS{
+				"3_add_about_to_users.up.sql":  {Data: []byte("up")},
+				"03_add_about_to_users.up.sql": {Data: []byte("up")},
+			},
+			ExpectErr: true,
+		},
+		{
+			Name: "names differ",
+			Files: fstest.MapFS{
+				"1_init.up.sql":   {Data: []byte("up")},
+				"1_other.up.sql":  {Data: []byte("up")},
+				"1_init.down.sql": {Data: []byte("down")},
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ms, err := Load(tc.Files)
+			if tc.ExpectErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d migrations", len(ms))
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if len(ms) != len(tc.ExpectedVersions) {
+				t.Errorf("expected %d migrations, got: %d", len(tc.ExpectedVersions), len(ms))
+				return
+			}
+			for i, m := range ms {
+				if m.Version != tc.ExpectedVersions[i] {
+					t.Errorf("expected version %d at %d, got: %d", tc.ExpectedVersions[i], i, m.Version)
+				}
+				if m.Up != "up" {
+					t.Errorf("expected migration %s to have its up file loaded", m)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	load := func(up string) *Migration {
+		ms, err := Load(fstest.MapFS{"1_init.up.sql": {Data: []byte(up)}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return ms[0]
+	}
+	if load("CREATE TABLE t ();").Checksum != load("CREATE TABLE t ();").Checksum {
+		t.Errorf("expected the same file to have the same checksum")
+	}
+	if load("CREATE TABLE t ();").Checksum == load("CREATE TABLE t (id int);").Checksum {
+		t.Errorf("expected a changed file to have another checksum")
+	}
+}
@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// noTransactionDirective is a comment line before the first statement of a file
+const noTransactionDirective = "-- migrate:no-transaction"
+
+// noTransaction tells whether the migration body starts with the no-transaction directive
+func noTransaction(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == noTransactionDirective:
+			return true
+		case line == "" || strings.HasPrefix(line, "--"):
+			continue
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// splitStatements splits a script on the semicolons outside of quotes and comments
+func splitStatements(script string) []string {
+	var statements []string
+	start, hasCode := 0, false
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"':
+			i = skipPast(script, i+1, string(c))
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			i = skipPast(script, i+2, "\n")
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			i = skipPast(script, i+2, "*/")
+			continue
+		case c == '$':
+			if tag, ok := dollarTag(script[i:]); ok {
+				i = skipPast(script, i+len(tag), tag)
+			}
+		case c == ';':
+			if hasCode {
+				statements = append(statements, strings.TrimSpace(script[start:i]))
+			}
+			start, hasCode = i+1, false
+			continue
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		}
+		hasCode = true
+	}
+	if hasCode {
+		statements = append(statements, strings.TrimSpace(script[start:]))
+	}
+	return statements
+}
+
+func skipPast(s string, i int, end string) int {
+	n := strings.Index(s[i:], end)
+	if n < 0 {
+		return len(s) - 1
+	}
+	return i + n + len(end) - 1
+}
+
+// dollarTag reads the $tag$ opening a dollar-quoted string at the start of s
+func dollarTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1], true
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+			continue
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
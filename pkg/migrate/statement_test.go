@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestNoTransaction(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Body     string
+		Expected bool
+	}{
+		{
+			Name:     "directive on the first line",
+			Body:     "-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY i ON t (c);",
+			Expected: true,
+		},
+		{
+			Name:     "directive after comments",
+			Body:     "\n-- adds the index without locking writes\n  -- migrate:no-transaction\nCREATE INDEX CONCURRENTLY i ON t (c);",
+			Expected: true,
+		},
+		{
+			Name:     "directive after a statement",
+			Body:     "SELECT 1;\n-- migrate:no-transaction\n",
+			Expected: false,
+		},
+		{
+			Name:     "no directive",
+			Body:     "BEGIN;\nCREATE INDEX i ON t (c);\nCOMMIT;",
+			Expected: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := noTransaction(tc.Body); got != tc.Expected {
+				t.Errorf("expected: %v, got: %v", tc.Expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Script   string
+		Expected []string
+	}{
+		{
+			Name:     "plain statements",
+			Script:   "CREATE INDEX a ON t (c);\nCREATE INDEX b ON t (d);\n",
+			Expected: []string{"CREATE INDEX a ON t (c)", "CREATE INDEX b ON t (d)"},
+		},
+		{
+			Name:     "last statement without a semicolon",
+			Script:   "SELECT 1; SELECT 2",
+			Expected: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			Name:     "semicolons in quotes",
+			Script:   `INSERT INTO t VALUES ('a;b', 'it''s;'); SELECT ";" FROM t;`,
+			Expected: []string{`INSERT INTO t VALUES ('a;b', 'it''s;')`, `SELECT ";" FROM t`},
+		},
+		{
+			Name:     "semicolons in comments",
+			Script:   "-- migrate:no-transaction\n-- first; second\nSELECT 1 /* ; */;\n-- trailing;\n",
+			Expected: []string{"-- migrate:no-transaction\n-- first; second\nSELECT 1 /* ; */"},
+		},
+		{
+			Name:   "dollar quotes",
+			Script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT $body$;$body$;",
+			Expected: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"SELECT $body$;$body$",
+			},
+		},
+		{
+			Name:     "positional parameters are not dollar quotes",
+			Script:   "PREPARE p AS SELECT $1; EXECUTE p(1);",
+			Expected: []string{"PREPARE p AS SELECT $1", "EXECUTE p(1)"},
+		},
+		{
+			Name:     "only comments",
+			Script:   "-- nothing to do;\n/* really; */",
+			Expected: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := splitStatements(tc.Script)
+			if len(got) != len(tc.Expected) {
+				t.Errorf("expected statements: %q, got: %q", tc.Expected, got)
+				return
+			}
+			for i := range got {
+				if got[i] != tc.Expected[i] {
+					t.Errorf("expected statements: %q, got: %q", tc.Expected, got)
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+//go:build integration
+// +build integration
+
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/seggga/postgres/migrations"
+	"github.com/seggga/postgres/pkg/migrate"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const (
+	DB_HOST     = "127.0.0.1"
+	DB_USER     = "gotuber"
+	DB_PASSWORD = "Passw0rd"
+	DB_NAME     = "go_tube"
+)
+
+var DB_PORT = ""
+
+const dockerMaxWait = time.Second * 5
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		log.Println("failed to create a new dockertest pool: ", err)
+		return -1
+	}
+	pool.MaxWait = dockerMaxWait
+	postgresContainer, err := runPostgresContainer(pool)
+	if err != nil {
+		log.Println("failed to run the Postgres container: ", err)
+		return -1
+	}
+	defer func() {
+		if err := pool.Purge(postgresContainer); err != nil {
+			log.Printf("failed to purge the Postgres container: %v", err)
+		}
+	}()
+	return m.Run()
+}
+
+func runPostgresContainer(pool *dockertest.Pool) (*dockertest.Resource, error) {
+	postgresContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "postgres",
+			Tag:        "14.0",
+			Env: []string{
+				"POSTGRES_USER=" + DB_USER,
+				"POSTGRES_PASSWORD=" + DB_PASSWORD,
+				"POSTGRES_DB=" + DB_NAME,
+			},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the postgres docker container: %w", err)
+	}
+	postgresContainer.Expire(120)
+
+	DB_PORT = postgresContainer.GetPort("5432/tcp")
+
+	// Wait for the DB to start
+	if err := pool.Retry(func() error {
+		conn, err := connect()
+		if err != nil {
+			return err
+		}
+		return conn.Close(context.Background())
+	}); err != nil {
+		pool.Purge(postgresContainer)
+		return nil, fmt.Errorf("failed to connect to the created DB: %w", err)
+	}
+	return postgresContainer, nil
+}
+
+func connect() (*pgx.Conn, error) {
+	return storage.ConnectPGX(context.Background(), &storage.ConnString{
+		Host:     DB_HOST,
+		Port:     DB_PORT,
+		User:     DB_USER,
+		Password: DB_PASSWORD,
+		DBName:   DB_NAME,
+	})
+}
+
+// TestRoundTrip applies every migration and rolls it back one by one, then applies them
+// again, so every down file has to undo its up file completely
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load the migrations: %v", err)
+	}
+	conn, err := connect()
+	if err != nil {
+		t.Fatalf("failed to connect to the DB: %v", err)
+	}
+	defer conn.Close(ctx)
+	m := migrate.New(conn, ms, nil)
+	last := ms[len(ms)-1].Version
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to apply the migrations: %v", err)
+	}
+	expectVersion(t, m, last)
+
+	for i := len(ms) - 1; i >= 0; i-- {
+		if err := m.Down(ctx, 1); err != nil {
+			t.Fatalf("failed to roll back migration %s: %v", ms[i], err)
+		}
+		expected := migrate.NilVersion
+		if i > 0 {
+			expected = ms[i-1].Version
+		}
+		expectVersion(t, m, expected)
+	}
+
+	var tables int
+	err = conn.QueryRow(ctx, `SELECT count(*) FROM pg_tables WHERE schemaname = 'public' AND tablename NOT IN ($1, $2)`,
+		migrate.Table, migrate.ChecksumTable).Scan(&tables)
+	if err != nil {
+		t.Fatalf("failed to count the tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("expected no tables left after rolling back, got: %d", tables)
+	}
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to apply the migrations again: %v", err)
+	}
+	expectVersion(t, m, last)
+
+	if err := m.Goto(ctx, ms[0].Version); err != nil {
+		t.Fatalf("failed to go to the first migration: %v", err)
+	}
+	expectVersion(t, m, ms[0].Version)
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to apply the migrations after goto: %v", err)
+	}
+	expectVersion(t, m, last)
+}
+
+// TestVerify changes the recorded checksum of an applied migration, as if its file was
+// edited after it was applied
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load the migrations: %v", err)
+	}
+	conn, err := connect()
+	if err != nil {
+		t.Fatalf("failed to connect to the DB: %v", err)
+	}
+	defer conn.Close(ctx)
+	m := migrate.New(conn, ms, nil)
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("failed to apply the migrations: %v", err)
+	}
+	if err := m.Verify(ctx); err != nil {
+		t.Fatalf("expected the applied migrations to verify, got: %v", err)
+	}
+
+	_, err = conn.Exec(ctx, `UPDATE `+migrate.ChecksumTable+` SET checksum = 'changed' WHERE version = $1`, ms[0].Version)
+	if err != nil {
+		t.Fatalf("failed to change the checksum: %v", err)
+	}
+	defer conn.Exec(ctx, `UPDATE `+migrate.ChecksumTable+` SET checksum = $1 WHERE version = $2`, ms[0].Checksum, ms[0].Version)
+	var verifyErr *migrate.VerifyError
+	if err := m.Verify(ctx); !errors.As(err, &verifyErr) || len(verifyErr.Problems) != 1 {
+		t.Errorf("expected the changed migration to be reported, got: %v", err)
+	}
+}
+
+func expectVersion(t *testing.T, m *migrate.Migrator, expected int64) {
+	t.Helper()
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get the migration status: %v", err)
+	}
+	if status.Dirty || status.Version != expected {
+		t.Fatalf("expected clean version %d, got: %d (dirty: %v)", expected, status.Version, status.Dirty)
+	}
+}
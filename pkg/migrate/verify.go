@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// VerifyError lists every disagreement Verify found
+type VerifyError struct {
+	Problems []string
+}
+
+func (e *VerifyError) Error() string {
+	return "migrations do not verify:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Verify compares the embedded migrations with the applied ones, it returns a *VerifyError
+func (m *Migrator) Verify(ctx context.Context) error {
+	_, err := m.verify(ctx, false)
+	return err
+}
+
+// VerifyCompatible is Verify accepting a newer schema, as an older build meets it during a rolling deploy
+func (m *Migrator) VerifyCompatible(ctx context.Context) (newer bool, err error) {
+	return m.verify(ctx, true)
+}
+
+func (m *Migrator) verify(ctx context.Context, allowNewer bool) (bool, error) {
+	version, dirty, err := m.version(ctx)
+	if err != nil {
+		return false, err
+	}
+	checksums, err := m.checksums(ctx)
+	if err != nil {
+		return false, err
+	}
+	newer := allowNewer && version > latest(m.migrations)
+	problems := checkSource(m.migrations)
+	problems = append(problems, checkApplied(m.migrations, version, dirty, checksums, newer)...)
+	if len(problems) > 0 {
+		return false, &VerifyError{Problems: problems}
+	}
+	return newer, nil
+}
+
+// latest returns the version of the last migration, NilVersion if there is none
+func latest(migrations []*Migration) int64 {
+	if len(migrations) == 0 {
+		return NilVersion
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// checkSource finds problems of the files alone, duplicate versions are already rejected by Load
+func checkSource(migrations []*Migration) []string {
+	var problems []string
+	for _, m := range migrations {
+		if m.Down == "" {
+			problems = append(problems, fmt.Sprintf("migration %s has no down file", m))
+		}
+	}
+	return problems
+}
+
+// checkApplied compares the applied version and checksums with the files, a newer schema
+// is only checked up to the last file
+func checkApplied(migrations []*Migration, version int64, dirty bool, checksums map[int64]string, newer bool) []string {
+	var problems []string
+	if dirty {
+		problems = append(problems, fmt.Sprintf("version %d is dirty, a migration failed half way", version))
+	}
+	if _, err := position(migrations, version); err != nil && !newer {
+		problems = append(problems, fmt.Sprintf("applied version %d is not among the embedded migrations", version))
+	}
+	known := make(map[int64]*Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+	versions := make([]int64, 0, len(checksums))
+	for v := range checksums {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	for _, v := range versions {
+		m, ok := known[v]
+		switch {
+		case !ok && newer && v > latest(migrations):
+			// applied by a newer build
+		case !ok:
+			problems = append(problems, fmt.Sprintf("applied migration %d is not among the embedded migrations", v))
+		case v > version:
+			problems = append(problems, fmt.Sprintf("migration %s has a checksum but is not applied", m))
+		case checksums[v] != m.Checksum:
+			problems = append(problems, fmt.Sprintf("migration %s changed after it was applied", m))
+		}
+	}
+	return problems
+}
+
+// checksums reads the checksums of the applied migrations, a missing table means there are none
+func (m *Migrator) checksums(ctx context.Context) (map[int64]string, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, checksum FROM `+ChecksumTable)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == pgCodeUndefinedTable:
+		return map[int64]string{}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read the migration checksums: %w", err)
+	}
+	defer rows.Close()
+	checksums := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to read the migration checksums: %w", err)
+		}
+		checksums[version] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		if errors.As(err, &pgErr) && pgErr.Code == pgCodeUndefinedTable {
+			return map[int64]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read the migration checksums: %w", err)
+	}
+	return checksums, nil
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"testing"
+)
+
+func TestCheckApplied(t *testing.T) {
+	ms := []*Migration{
+		{Version: 1, Name: "init", Up: "up", Down: "down", Checksum: "c1"},
+		{Version: 2, Name: "add_indexes", Up: "up", Down: "down", Checksum: "c2"},
+		{Version: 3, Name: "add_about_to_users", Up: "up", Down: "down", Checksum: "c3"},
+	}
+	cases := []struct {
+		Name             string
+		Version          int64
+		Dirty            bool
+		Checksums        map[int64]string
+		Newer            bool
+		ExpectedProblems int
+	}{
+		{
+			Name:      "clean DB",
+			Version:   NilVersion,
+			Checksums: map[int64]string{},
+		},
+		{
+			Name:      "partially applied",
+			Version:   2,
+			Checksums: map[int64]string{1: "c1", 2: "c2"},
+		},
+		{
+			Name:      "applied by golang-migrate",
+			Version:   3,
+			Checksums: map[int64]string{},
+		},
+		{
+			Name:             "dirty",
+			Version:          2,
+			Dirty:            true,
+			Checksums:        map[int64]string{1: "c1"},
+			ExpectedProblems: 1,
+		},
+		{
+			Name:             "unknown version",
+			Version:          4,
+			Checksums:        map[int64]string{1: "c1", 2: "c2", 3: "c3"},
+			ExpectedProblems: 1,
+		},
+		{
+			Name:      "newer schema",
+			Version:   4,
+			Checksums: map[int64]string{1: "c1", 2: "c2", 3: "c3", 4: "c4"},
+			Newer:     true,
+		},
+		{
+			Name:             "newer schema with changed files",
+			Version:          4,
+			Checksums:        map[int64]string{1: "c1", 2: "changed", 4: "c4"},
+			Newer:            true,
+			ExpectedProblems: 1,
+		},
+		{
+			Name:             "changed files",
+			Version:          3,
+			Checksums:        map[int64]string{1: "c1", 2: "changed", 3: "changed"},
+			ExpectedProblems: 2,
+		},
+		{
+			Name:             "checksum of an unknown migration",
+			Version:          3,
+			Checksums:        map[int64]string{1: "c1", 5: "c5"},
+			ExpectedProblems: 1,
+		},
+		{
+			Name:             "checksum of a rolled back migration",
+			Version:          1,
+			Checksums:        map[int64]string{1: "c1", 2: "c2"},
+			ExpectedProblems: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			problems := checkApplied(ms, tc.Version, tc.Dirty, tc.Checksums, tc.Newer)
+			if len(problems) != tc.ExpectedProblems {
+				t.Errorf("expected %d problems, got: %q", tc.ExpectedProblems, problems)
+			}
+		})
+	}
+}
+
+func TestCheckSource(t *testing.T) {
+	ms := []*Migration{
+		{Version: 1, Name: "init", Up: "up", Down: "down"},
+		{Version: 2, Name: "add_indexes", Up: "up"},
+	}
+	if problems := checkSource(ms); len(problems) != 1 {
+		t.Errorf("expected the missing down file to be reported, got: %q", problems)
+	}
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	cases := []struct {
+		Name      string
+		Exporter  string
+		ExpectErr bool
+	}{
+		{
+			Name:     "stdout",
+			Exporter: ExporterStdout,
+		},
+		{
+			Name:     "otlp",
+			Exporter: ExporterOTLP,
+		},
+		{
+			Name:      "unknown",
+			Exporter:  "jaeger",
+			ExpectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			tp, err := NewProvider(context.Background(), Config{
+				Exporter:     tc.Exporter,
+				ServiceName:  "video-hint",
+				OTLPEndpoint: "localhost:4318",
+				OTLPInsecure: true,
+				SampleRatio:  1,
+				Out:          &out,
+			})
+			if (err != nil) != tc.ExpectErr {
+				t.Fatalf("expected error: %v, got: %v", tc.ExpectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if tc.Exporter != ExporterStdout {
+				return
+			}
+			_, span := tp.Tracer("test").Start(context.Background(), "search")
+			span.End()
+			if err := tp.Shutdown(context.Background()); err != nil {
+				t.Fatalf("failed to flush the spans: %v", err)
+			}
+			for _, field := range []string{`"Name":"search"`, `"Value":"video-hint"`} {
+				if !strings.Contains(out.String(), field) {
+					t.Errorf("expected the exported span to contain %s, got: %s", field, out.String())
+				}
+			}
+		})
+	}
+}
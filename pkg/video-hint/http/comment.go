@@ -0,0 +1,146 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+// UserIDHeader carries the acting user, it is trusted as there is no authentication yet
+const UserIDHeader = "X-User-ID"
+
+// commentRequest is the body of posting and editing a comment
+type commentRequest struct {
+	Body string `json:"body"`
+}
+
+type commentCount struct {
+	VideoID int `json:"video_id"`
+	Count   int `json:"count"`
+}
+
+func (h *Handler) PostComment(w http.ResponseWriter, r *http.Request, videoID string) {
+	vID, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var req commentRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	c, err := service.PostComment(r.Context(), h.db, &storage.Comment{
+		UserID:  userID,
+		VideoID: vID,
+		Body:    req.Body,
+	})
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/comments/%d", c.ID))
+	writeJSON(w, r, http.StatusCreated, c)
+}
+
+// ListComments pages through the comments of a video, see order, limit and cursor query parameters
+func (h *Handler) ListComments(w http.ResponseWriter, r *http.Request, videoID string) {
+	vID, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	query := r.URL.Query()
+	limit, err := parseIntParam(query, "limit")
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	page, err := service.ListComments(r.Context(), h.db, &service.CommentRequest{
+		VideoID: vID,
+		Order:   query.Get("order"),
+		Limit:   limit,
+		Cursor:  query.Get("cursor"),
+	}, h.cfg.PageLimits)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+func (h *Handler) CountComments(w http.ResponseWriter, r *http.Request, videoID string) {
+	vID, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	count, err := service.CountComments(r.Context(), h.db, vID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, &commentCount{
+		VideoID: vID,
+		Count:   count,
+	})
+}
+
+func (h *Handler) EditComment(w http.ResponseWriter, r *http.Request, commentID string) {
+	id, err := parseID(commentID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var req commentRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	c, err := service.EditComment(r.Context(), h.db, id, userID, req.Body)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, c)
+}
+
+func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request, commentID string) {
+	id, err := parseID(commentID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	if err := service.DeleteComment(r.Context(), h.db, id, userID); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseUserID(r *http.Request) (int, error) {
+	val := r.Header.Get(UserIDHeader)
+	id, err := strconv.Atoi(val)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: header %s must hold a positive user id, got %q", errUnauthenticated, UserIDHeader, val)
+	}
+	return id, nil
+}
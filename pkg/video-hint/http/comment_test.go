@@ -0,0 +1,210 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestCommentHandlers(t *testing.T) {
+	cases := []struct {
+		Name             string
+		Method           string
+		Path             string
+		UserID           string
+		Body             string
+		MockErr          error
+		ExpectedRespCode int
+	}{
+		{
+			Name:             "post",
+			Method:           "POST",
+			Path:             "/videos/1/comments",
+			UserID:           "1",
+			Body:             `{"body": "nice"}`,
+			ExpectedRespCode: http.StatusCreated,
+		},
+		{
+			Name:             "post anonymously",
+			Method:           "POST",
+			Path:             "/videos/1/comments",
+			Body:             `{"body": "nice"}`,
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Name:             "post empty body",
+			Method:           "POST",
+			Path:             "/videos/1/comments",
+			UserID:           "1",
+			Body:             `{"body": ""}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "post on missing video",
+			Method:           "POST",
+			Path:             "/videos/1/comments",
+			UserID:           "1",
+			Body:             `{"body": "nice"}`,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "comments_fk_video_id", Field: "video_id"},
+			ExpectedRespCode: http.StatusNotFound,
+		},
+		{
+			Name:             "list",
+			Method:           "GET",
+			Path:             "/videos/1/comments?order=asc&limit=10",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "list malformed cursor",
+			Method:           "GET",
+			Path:             "/videos/1/comments?cursor=abc",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "count",
+			Method:           "GET",
+			Path:             "/videos/1/comments/count",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "count of missing video",
+			Method:           "GET",
+			Path:             "/videos/1/comments/count",
+			MockErr:          fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedRespCode: http.StatusNotFound,
+		},
+		{
+			Name:             "edit own",
+			Method:           "PATCH",
+			Path:             "/comments/1",
+			UserID:           "1",
+			Body:             `{"body": "edited"}`,
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "edit of another user",
+			Method:           "PATCH",
+			Path:             "/comments/1",
+			UserID:           "2",
+			Body:             `{"body": "edited"}`,
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			Name:             "delete own",
+			Method:           "DELETE",
+			Path:             "/comments/1",
+			UserID:           "1",
+			ExpectedRespCode: http.StatusNoContent,
+		},
+		{
+			Name:             "delete of another user",
+			Method:           "DELETE",
+			Path:             "/comments/1",
+			UserID:           "2",
+			ExpectedRespCode: http.StatusForbidden,
+		},
+		{
+			Name:             "delete missing",
+			Method:           "DELETE",
+			Path:             "/comments/1",
+			UserID:           "1",
+			MockErr:          fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedRespCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, tc.Path, strings.NewReader(tc.Body))
+			if err != nil {
+				t.Errorf("failed to create an http request: %v", err)
+				return
+			}
+			if tc.UserID != "" {
+				req.Header.Set(UserIDHeader, tc.UserID)
+			}
+			h := NewHandler(&commentDBMock{err: tc.MockErr, authorID: 1}, Config{
+				PageLimits:          service.DefaultPageLimits,
+				SimilarityThreshold: service.DefaultSimilarityThreshold,
+			})
+
+			handler := mux.NewRouter()
+			handler.HandleFunc("/videos/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+				h.PostComment(w, r, mux.Vars(r)["id"])
+			}).Methods("POST")
+			handler.HandleFunc("/videos/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+				h.ListComments(w, r, mux.Vars(r)["id"])
+			}).Methods("GET")
+			handler.HandleFunc("/videos/{id}/comments/count", func(w http.ResponseWriter, r *http.Request) {
+				h.CountComments(w, r, mux.Vars(r)["id"])
+			}).Methods("GET")
+			handler.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.EditComment(w, r, mux.Vars(r)["id"])
+			}).Methods("PATCH")
+			handler.HandleFunc("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.DeleteComment(w, r, mux.Vars(r)["id"])
+			}).Methods("DELETE")
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+// commentDBMock fails every comment call with err, the comments it gets belong to authorID
+type commentDBMock struct {
+	storage.DB
+
+	err      error
+	authorID int
+}
+
+func (db *commentDBMock) CreateComment(ctx context.Context, c *storage.Comment) (*storage.Comment, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	created := *c
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *commentDBMock) GetComment(ctx context.Context, id int) (*storage.Comment, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Comment{ID: id, UserID: db.authorID}, nil
+}
+
+func (db *commentDBMock) GetVideo(ctx context.Context, id int) (*storage.Video, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *commentDBMock) ListComments(ctx context.Context, q *storage.CommentQuery) ([]*storage.Comment, error) {
+	return []*storage.Comment{}, db.err
+}
+
+func (db *commentDBMock) UpdateComment(ctx context.Context, id int, userID int, body string) (*storage.Comment, error) {
+	return &storage.Comment{ID: id, UserID: userID, Body: body}, db.err
+}
+
+func (db *commentDBMock) DeleteComment(ctx context.Context, id int, userID int) error {
+	return db.err
+}
+
+func (db *commentDBMock) CountComments(ctx context.Context, videoID int) (int, error) {
+	return 0, db.err
+}
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const (
+	checkOK        = "ok"
+	checkFailed    = "failed"
+	checkSaturated = "saturated"
+
+	defaultReadinessTimeout = time.Second * 2
+)
+
+// ReadinessConfig tells the expected schema version, Timeout and Logger have defaults
+type ReadinessConfig struct {
+	SchemaVersion int64
+	Timeout       time.Duration
+	Logger        *logrus.Logger
+}
+
+// Readiness serves /readyz, a saturated pool keeps the instance ready not to overload the rest
+type Readiness struct {
+	db       storage.DB
+	cfg      ReadinessConfig
+	draining int32
+}
+
+func NewReadiness(db storage.DB, cfg ReadinessConfig) *Readiness {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultReadinessTimeout
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = logrus.StandardLogger()
+	}
+	return &Readiness{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+type healthResponse struct {
+	Status string           `json:"status"`
+	Checks *readinessChecks `json:"checks,omitempty"`
+}
+
+type readinessChecks struct {
+	DB     checkResult  `json:"db"`
+	Schema schemaResult `json:"schema"`
+	Pool   poolResult   `json:"pool"`
+}
+
+type checkResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type schemaResult struct {
+	checkResult
+	Version  *int64 `json:"version,omitempty"`
+	Expected int64  `json:"expected"`
+	Dirty    bool   `json:"dirty,omitempty"`
+}
+
+type poolResult struct {
+	checkResult
+	storage.PoolStats
+}
+
+// Drain marks the instance as shutting down
+func (r *Readiness) Drain() {
+	atomic.StoreInt32(&r.draining, 1)
+}
+
+// Draining tells whether Drain has been called
+func (r *Readiness) Draining() bool {
+	return atomic.LoadInt32(&r.draining) == 1
+}
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.Draining() {
+		writeJSON(w, req, http.StatusServiceUnavailable, healthResponse{Status: "draining"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), r.cfg.Timeout)
+	defer cancel()
+	checks := &readinessChecks{
+		DB:     r.checkDB(ctx),
+		Schema: r.checkSchema(ctx),
+		Pool:   r.checkPool(),
+	}
+	if checks.DB.Status != checkOK || checks.Schema.Status != checkOK {
+		writeJSON(w, req, http.StatusServiceUnavailable, healthResponse{Status: "unready", Checks: checks})
+		return
+	}
+	writeJSON(w, req, http.StatusOK, healthResponse{Status: "ready", Checks: checks})
+}
+
+func (r *Readiness) checkDB(ctx context.Context) checkResult {
+	if err := r.db.Ping(ctx); err != nil {
+		r.cfg.Logger.Errorf("readiness: DB ping failed: %v", err)
+		return checkResult{Status: checkFailed, Error: "unreachable"}
+	}
+	return checkResult{Status: checkOK}
+}
+
+// checkSchema accepts newer versions, they are applied while a new build rolls out
+func (r *Readiness) checkSchema(ctx context.Context) schemaResult {
+	res := schemaResult{Expected: r.cfg.SchemaVersion}
+	version, dirty, err := r.db.SchemaVersion(ctx)
+	if errors.Is(err, storage.ErrNoSchemaVersion) {
+		res.checkResult = checkResult{Status: checkFailed, Error: "not migrated"}
+		return res
+	}
+	if err != nil {
+		r.cfg.Logger.Errorf("readiness: schema version query failed: %v", err)
+		res.checkResult = checkResult{Status: checkFailed, Error: "query failed"}
+		return res
+	}
+	res.Version, res.Dirty = &version, dirty
+	switch {
+	case dirty:
+		res.checkResult = checkResult{Status: checkFailed, Error: "a migration failed half way"}
+	case version < r.cfg.SchemaVersion:
+		res.checkResult = checkResult{Status: checkFailed, Error: "pending migrations"}
+	default:
+		res.checkResult = checkResult{Status: checkOK}
+	}
+	return res
+}
+
+func (r *Readiness) checkPool() poolResult {
+	res := poolResult{
+		checkResult: checkResult{Status: checkOK},
+		PoolStats:   r.db.Stats(),
+	}
+	if res.Saturated() {
+		res.Status = checkSaturated
+	}
+	return res
+}
+
+// Liveness serves /healthz, a DB outage must not get the instance restarted
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, healthResponse{Status: checkOK})
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/seggga/postgres/pkg/logging"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestReadiness(t *testing.T) {
+	cases := []struct {
+		Name             string
+		DB               *healthDBMock
+		Drain            bool
+		ExpectedRespCode int
+		ExpectedFields   []string
+	}{
+		{
+			Name:             "ready",
+			DB:               &healthDBMock{version: 2},
+			ExpectedRespCode: http.StatusOK,
+			ExpectedFields:   []string{`"status":"ready"`, `"db":{"status":"ok"}`, `"version":2`, `"acquired_conns":1`},
+		},
+		{
+			Name:             "newer schema",
+			DB:               &healthDBMock{version: 3},
+			ExpectedRespCode: http.StatusOK,
+			ExpectedFields:   []string{`"status":"ready"`, `"version":3,"expected":2`},
+		},
+		{
+			Name:             "saturated pool",
+			DB:               &healthDBMock{version: 2, stats: storage.PoolStats{MaxConns: 4, TotalConns: 4, AcquiredConns: 4}},
+			ExpectedRespCode: http.StatusOK,
+			ExpectedFields:   []string{`"status":"ready"`, `"pool":{"status":"saturated"`},
+		},
+		{
+			Name:             "ping failed",
+			DB:               &healthDBMock{version: 2, pingErr: fmt.Errorf("connection refused")},
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`"status":"unready"`, `"db":{"status":"failed","error":"unreachable"}`},
+		},
+		{
+			Name:             "pending migrations",
+			DB:               &healthDBMock{version: 1},
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`"status":"unready"`, `"error":"pending migrations","version":1,"expected":2`},
+		},
+		{
+			Name:             "dirty schema",
+			DB:               &healthDBMock{version: 2, dirty: true},
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`"status":"unready"`, `"dirty":true`},
+		},
+		{
+			Name:             "no schema",
+			DB:               &healthDBMock{versionErr: storage.ErrNoSchemaVersion},
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`"status":"unready"`, `"error":"not migrated"`},
+		},
+		{
+			Name:             "schema query failed",
+			DB:               &healthDBMock{versionErr: fmt.Errorf("permission denied for table schema_migrations")},
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`"status":"unready"`, `"error":"query failed"`},
+		},
+		{
+			Name:             "draining",
+			DB:               &healthDBMock{version: 2},
+			Drain:            true,
+			ExpectedRespCode: http.StatusServiceUnavailable,
+			ExpectedFields:   []string{`{"status":"draining"}`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ready := NewReadiness(tc.DB, ReadinessConfig{SchemaVersion: 2, Logger: logging.New(io.Discard, logrus.InfoLevel)})
+			if tc.Drain {
+				ready.Drain()
+			}
+			w := httptest.NewRecorder()
+			ready.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tc.ExpectedRespCode {
+				t.Errorf("expected status code: %d, got: %d", tc.ExpectedRespCode, w.Code)
+			}
+			for _, field := range tc.ExpectedFields {
+				if !strings.Contains(w.Body.String(), field) {
+					t.Errorf("expected the body to contain %s, got: %s", field, w.Body.String())
+				}
+			}
+			if tc.DB.pingErr != nil && strings.Contains(w.Body.String(), tc.DB.pingErr.Error()) {
+				t.Errorf("expected the DB error to be kept out of the body, got: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	w := httptest.NewRecorder()
+	Liveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code: %d, got: %d", http.StatusOK, w.Code)
+	}
+}
+
+type healthDBMock struct {
+	storage.DB
+
+	pingErr    error
+	version    int64
+	dirty      bool
+	versionErr error
+	stats      storage.PoolStats
+}
+
+func (db *healthDBMock) Ping(ctx context.Context) error {
+	return db.pingErr
+}
+
+func (db *healthDBMock) SchemaVersion(ctx context.Context) (int64, bool, error) {
+	return db.version, db.dirty, db.versionErr
+}
+
+func (db *healthDBMock) Stats() storage.PoolStats {
+	if db.stats == (storage.PoolStats{}) {
+		return storage.PoolStats{MaxConns: 4, TotalConns: 2, AcquiredConns: 1, IdleConns: 1}
+	}
+	return db.stats
+}
@@ -1,52 +1,90 @@
 package http
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/seggga/postgres/pkg/video-hint/service"
 	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
 
-func GetVideosByCaption(w http.ResponseWriter, r *http.Request, captionSubstring string) {
-	dbIface := r.Context().Value(storage.ContextKeyDB)
-	if dbIface == nil {
-		log.Println("DB is not found in the request context")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+// Config holds the tunables of the HTTP handlers, SearchObserver may be nil
+type Config struct {
+	PageLimits          service.PageLimits
+	SimilarityThreshold float64
+	SearchObserver      service.SearchObserver
+}
+
+// Handler serves the video-hint endpoints using the DB shared by all requests
+type Handler struct {
+	db  storage.DB
+	cfg Config
+}
+
+func NewHandler(db storage.DB, cfg Config) *Handler {
+	return &Handler{
+		db:  db,
+		cfg: cfg,
 	}
-	db, ok := dbIface.(storage.DB)
-	if !ok {
-		log.Println("DB in the request context is not of type storage.DB")
-		w.WriteHeader(http.StatusInternalServerError)
+}
+
+func (h *Handler) GetVideosByCaption(w http.ResponseWriter, r *http.Request, captionSubstring string) {
+	req, err := parseSearchRequest(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, err)
 		return
 	}
-	videos, err := service.GetVideosByCaption(db, captionSubstring)
+	req.Phrase = captionSubstring
+	req.Threshold = h.cfg.SimilarityThreshold
+	videos, err := service.GetVideosByCaption(r.Context(), h.db, req, h.cfg.PageLimits, h.cfg.SearchObserver)
 	if err != nil {
-		log.Println(err)
-		if errors.Is(err, service.ErrIncorrectCaptionSubstring) {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, service.ErrDBRequestFailed) {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProblem(w, r, err)
 		return
 	}
-	resp, err := json.Marshal(videos)
+	writeJSON(w, r, http.StatusOK, videos)
+}
+
+func parseSearchRequest(query url.Values) (*service.SearchRequest, error) {
+	req := &service.SearchRequest{
+		Mode:   query.Get("mode"),
+		Sort:   query.Get("sort"),
+		Order:  query.Get("order"),
+		Cursor: query.Get("cursor"),
+	}
+	var err error
+	if req.Fuzzy, err = parseBoolParam(query, "fuzzy"); err != nil {
+		return nil, err
+	}
+	if req.Limit, err = parseIntParam(query, "limit"); err != nil {
+		return nil, err
+	}
+	if req.Offset, err = parseIntParam(query, "offset"); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func parseIntParam(query url.Values, name string) (int, error) {
+	val := query.Get(name)
+	if val == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
 	if err != nil {
-		log.Printf("failed to serialize videos list to JSON: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return 0, fieldError(name, "must be an integer, got %q", val)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	if _, err := w.Write(resp); err != nil {
-		log.Printf("failed to write videos list as a response body: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	return n, nil
+}
+
+func parseBoolParam(query url.Values, name string) (bool, error) {
+	val := query.Get(name)
+	if val == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fieldError(name, "must be a boolean, got %q", val)
 	}
+	return b, nil
 }
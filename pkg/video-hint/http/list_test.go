@@ -8,12 +8,14 @@ import (
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/seggga/postgres/pkg/video-hint/service"
 	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
 
 func TestGetVideosByCaption(t *testing.T) {
 	cases := []struct {
 		CaptionSubstring  string
+		Query             string
 		ExpectedSubstring string
 		MockErr           error
 		ExpectedRespCode  int
@@ -27,11 +29,72 @@ func TestGetVideosByCaption(t *testing.T) {
 			CaptionSubstring: "",
 			ExpectedRespCode: http.StatusBadRequest,
 		},
+		{
+			CaptionSubstring: "repudiandae",
+			Query:            "?limit=ten",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			CaptionSubstring: "repudiandae",
+			Query:            "?limit=1000",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			CaptionSubstring:  "repudiandae",
+			Query:             "?limit=10&offset=20",
+			ExpectedSubstring: "repudiandae",
+			ExpectedRespCode:  http.StatusOK,
+		},
+		{
+			CaptionSubstring:  "repudiandae",
+			Query:             "?sort=likes&order=asc",
+			ExpectedSubstring: "repudiandae",
+			ExpectedRespCode:  http.StatusOK,
+		},
+		{
+			CaptionSubstring:  "repudiandae",
+			Query:             "?mode=fulltext",
+			ExpectedSubstring: "repudiandae",
+			ExpectedRespCode:  http.StatusOK,
+		},
+		{
+			CaptionSubstring:  "repudiandae",
+			Query:             "?fuzzy=true",
+			ExpectedSubstring: "repudiandae",
+			ExpectedRespCode:  http.StatusOK,
+		},
+		{
+			CaptionSubstring: "repudiandae",
+			Query:            "?fuzzy=maybe",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			CaptionSubstring:  "repudiandae_",
+			Query:             "?mode=pattern",
+			ExpectedSubstring: "repudiandae_",
+			ExpectedRespCode:  http.StatusOK,
+		},
+		{
+			CaptionSubstring: "repudiandae",
+			Query:            "?mode=regexp",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			CaptionSubstring: "repudiandae",
+			Query:            "?sort=views",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
 		{
 			CaptionSubstring:  "alidd",
 			ExpectedSubstring: "alidd",
 			MockErr:           fmt.Errorf("some err"),
-			ExpectedRespCode:  http.StatusInternalServerError,
+			ExpectedRespCode:  http.StatusServiceUnavailable,
+		},
+		{
+			CaptionSubstring:  "alidd",
+			ExpectedSubstring: "alidd",
+			MockErr:           fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedRespCode:  http.StatusGatewayTimeout,
 		},
 	}
 
@@ -40,21 +103,24 @@ func TestGetVideosByCaption(t *testing.T) {
 			t.Logf("caption substring: %s, expected resp code: %d", tc.CaptionSubstring, tc.ExpectedRespCode)
 
 			urlPath := fmt.Sprintf("/videos/%s", tc.CaptionSubstring)
-			req, err := http.NewRequest("GET", urlPath, nil)
+			req, err := http.NewRequest("GET", urlPath+tc.Query, nil)
 			if err != nil {
 				t.Errorf("failed to create an http request: %v", err)
 				return
 			}
-			req = req.WithContext(context.WithValue(req.Context(), storage.ContextKeyDB, &dbMock{
+			h := NewHandler(&dbMock{
 				t:                 t,
 				expectedSubstring: tc.CaptionSubstring,
 				expectedError:     tc.MockErr,
 				videosToReturn:    nil,
-			}))
+			}, Config{
+				PageLimits:          service.DefaultPageLimits,
+				SimilarityThreshold: service.DefaultSimilarityThreshold,
+			})
 
 			handler := mux.NewRouter()
 			handler.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
-				GetVideosByCaption(w, r, tc.CaptionSubstring)
+				h.GetVideosByCaption(w, r, tc.CaptionSubstring)
 			}).Methods("GET")
 			rr := httptest.NewRecorder()
 
@@ -68,18 +134,41 @@ func TestGetVideosByCaption(t *testing.T) {
 }
 
 type dbMock struct {
+	// the methods the search does not call are left unimplemented
+	storage.DB
+
 	t                 *testing.T
 	expectedSubstring string
 	expectedError     error
 	videosToReturn    []*storage.FoundVideo
 }
 
-func (db *dbMock) GetVideosByCaption(ctx context.Context, searchPhrase string) ([]*storage.FoundVideo, error) {
-	if searchPhrase != db.expectedSubstring {
-		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedSubstring, searchPhrase)
+func (db *dbMock) GetVideosByCaption(ctx context.Context, q *storage.CaptionQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedSubstring {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedSubstring, q.Phrase)
+		return nil, nil
+	}
+	return db.videosToReturn, db.expectedError
+}
+
+func (db *dbMock) SearchVideos(ctx context.Context, q *storage.TextQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedSubstring {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedSubstring, q.Phrase)
+		return nil, nil
+	}
+	return db.videosToReturn, db.expectedError
+}
+
+func (db *dbMock) GetVideosByCaptionSimilarity(ctx context.Context, q *storage.SimilarityQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedSubstring {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedSubstring, q.Phrase)
 		return nil, nil
 	}
 	return db.videosToReturn, db.expectedError
 }
 
 func (db *dbMock) Close() {}
+
+func (db *dbMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
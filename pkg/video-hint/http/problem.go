@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/seggga/postgres/pkg/logging"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+)
+
+// problemContentType is the media type of the error bodies, see RFC 7807
+const problemContentType = "application/problem+json"
+
+var (
+	errIncorrectRequest = &service.Error{Kind: service.KindValidation, Code: "incorrect_request", Message: "got an incorrect request"}
+	errUnauthenticated  = &service.Error{Kind: service.KindUnauthenticated, Code: "unauthenticated", Message: "the acting user is unknown"}
+	errRouteNotFound    = &service.Error{Kind: service.KindNotFound, Code: "route_not_found", Message: "no such endpoint"}
+	errMethodNotAllowed = &service.Error{Kind: service.KindMethodNotAllowed, Code: "method_not_allowed", Message: "the endpoint does not support the method"}
+)
+
+// problem is the body of every error response, Code tells the errors of a status apart
+type problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail"`
+	Instance  string               `json:"instance"`
+	Code      string               `json:"code"`
+	Errors    []service.FieldError `json:"errors,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+}
+
+// writeProblem logs err and answers it with its kind only, so the DB errors do not leak
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	e := service.AsError(err)
+	status := problemStatus(e.Kind)
+	if status < http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Info(err)
+	} else {
+		logging.FromContext(r.Context()).Error(err)
+	}
+	p := &problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	var fieldErrs *service.FieldErrors
+	if errors.As(err, &fieldErrs) {
+		p.Errors = fieldErrs.Fields
+	}
+	resp, mErr := json.Marshal(p)
+	if mErr != nil {
+		logging.FromContext(r.Context()).Errorf("failed to serialize the problem to JSON: %v", mErr)
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		logging.FromContext(r.Context()).Errorf("failed to write the response body: %v", err)
+	}
+}
+
+func problemStatus(kind service.Kind) int {
+	switch kind {
+	case service.KindValidation:
+		return http.StatusBadRequest
+	case service.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case service.KindForbidden:
+		return http.StatusForbidden
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case service.KindConflict:
+		return http.StatusConflict
+	case service.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case service.KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// NotFound answers the requests matching no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, errRouteNotFound)
+}
+
+// MethodNotAllowed answers the requests matching a route by path only
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, errMethodNotAllowed)
+}
+
+// fieldError marks a request parameter the handler could not parse
+func fieldError(field string, format string, args ...interface{}) error {
+	return &service.FieldErrors{
+		Kind:   errIncorrectRequest,
+		Fields: []service.FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}},
+	}
+}
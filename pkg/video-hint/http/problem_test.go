@@ -0,0 +1,208 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/seggga/postgres/pkg/logging"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestWriteProblem(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Path           string
+		UserID         string
+		MockErr        error
+		ExpectedStatus int
+		ExpectedCode   string
+		ExpectedDetail string
+		ExpectedFields []string
+	}{
+		{
+			Name:           "incorrect user",
+			Path:           "/videos/1/like",
+			UserID:         "-1",
+			ExpectedStatus: http.StatusUnauthorized,
+			ExpectedCode:   "unauthenticated",
+		},
+		{
+			Name:           "constraint violation",
+			Path:           "/videos/1/like",
+			UserID:         "1",
+			MockErr:        &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_user_id", Field: "user_id"},
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   "incorrect_vote",
+			ExpectedDetail: service.ErrIncorrectVote.Error(),
+		},
+		{
+			Name:           "malformed id",
+			Path:           "/videos/one/like",
+			UserID:         "1",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedCode:   "incorrect_request",
+			ExpectedFields: []string{"id"},
+		},
+		{
+			Name:           "no user",
+			Path:           "/videos/1/like",
+			ExpectedStatus: http.StatusUnauthorized,
+			ExpectedCode:   "unauthenticated",
+		},
+		{
+			Name:           "not found",
+			Path:           "/videos/1/like",
+			UserID:         "1",
+			MockErr:        fmt.Errorf("%w: no rows in result set", storage.ErrNotFound),
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   "not_found",
+			ExpectedDetail: service.ErrNotFound.Error(),
+		},
+		{
+			Name:           "db outage",
+			Path:           "/videos/1/like",
+			UserID:         "1",
+			MockErr:        fmt.Errorf("dial tcp 10.0.0.7:5432: connection refused"),
+			ExpectedStatus: http.StatusServiceUnavailable,
+			ExpectedCode:   "db_unavailable",
+			ExpectedDetail: service.ErrDBRequestFailed.Error(),
+		},
+		{
+			Name:           "timeout",
+			Path:           "/videos/1/like",
+			UserID:         "1",
+			MockErr:        fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedStatus: http.StatusGatewayTimeout,
+			ExpectedCode:   "timeout",
+			ExpectedDetail: service.ErrTimeout.Error(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			h := NewHandler(&voteDBMock{err: tc.MockErr}, Config{PageLimits: service.DefaultPageLimits})
+			r := mux.NewRouter()
+			var logs bytes.Buffer
+			r.Use(logging.Middleware(logging.New(&logs, logrus.InfoLevel)))
+			r.HandleFunc("/videos/{id}/like", func(w http.ResponseWriter, r *http.Request) {
+				h.Like(w, r, mux.Vars(r)["id"])
+			}).Methods("PUT")
+
+			req := httptest.NewRequest(http.MethodPut, tc.Path, nil)
+			req.Header.Set(logging.RequestIDHeader, "req-42")
+			if tc.UserID != "" {
+				req.Header.Set(UserIDHeader, tc.UserID)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.ExpectedStatus {
+				t.Fatalf("expected status code: %d, got: %d", tc.ExpectedStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("expected content type: %s, got: %s", problemContentType, ct)
+			}
+			var p problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("failed to decode the problem %q: %v", w.Body.String(), err)
+			}
+			if p.Status != tc.ExpectedStatus || p.Title != http.StatusText(tc.ExpectedStatus) {
+				t.Errorf("expected the problem of status %d, got: %d %q", tc.ExpectedStatus, p.Status, p.Title)
+			}
+			if p.Code != tc.ExpectedCode {
+				t.Errorf("expected code: %s, got: %s", tc.ExpectedCode, p.Code)
+			}
+			if tc.ExpectedDetail != "" && p.Detail != tc.ExpectedDetail {
+				t.Errorf("expected detail: %q, got: %q", tc.ExpectedDetail, p.Detail)
+			}
+			if strings.Contains(w.Body.String(), "likes_fk_user_id") || strings.Contains(w.Body.String(), "no rows") {
+				t.Errorf("expected the DB error to stay out of the response, got: %s", w.Body.String())
+			}
+			if p.RequestID != "req-42" || p.Instance != tc.Path {
+				t.Errorf("expected the problem to carry the request ID and path, got: %q and %q", p.RequestID, p.Instance)
+			}
+			// the client errors are no failures of the service
+			expectedLevel := "info"
+			if tc.ExpectedStatus >= http.StatusInternalServerError {
+				expectedLevel = "error"
+			}
+			var line map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.SplitN(logs.String(), "\n", 2)[0]), &line); err != nil {
+				t.Fatalf("failed to parse the log line %q: %v", logs.String(), err)
+			}
+			if line["level"] != expectedLevel {
+				t.Errorf("expected the error to be logged at %s, got: %v", expectedLevel, line["level"])
+			}
+			fields := make([]string, 0, len(p.Errors))
+			for _, f := range p.Errors {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.ExpectedFields, ",") {
+				t.Errorf("expected errors of fields %v, got %v", tc.ExpectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestUnmatchedRoute(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Method         string
+		Path           string
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{
+			Name:           "unknown path",
+			Method:         http.MethodGet,
+			Path:           "/nowhere",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedCode:   "route_not_found",
+		},
+		{
+			Name:           "unsupported method",
+			Method:         http.MethodPost,
+			Path:           "/videos/1/like",
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			ExpectedCode:   "method_not_allowed",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := mux.NewRouter()
+			mw := logging.Middleware(logging.New(&bytes.Buffer{}, logrus.InfoLevel))
+			r.NotFoundHandler = mw(http.HandlerFunc(NotFound))
+			r.MethodNotAllowedHandler = mw(http.HandlerFunc(MethodNotAllowed))
+			r.HandleFunc("/videos/{id}/like", func(w http.ResponseWriter, r *http.Request) {}).Methods("PUT")
+
+			req := httptest.NewRequest(tc.Method, tc.Path, nil)
+			req.Header.Set(logging.RequestIDHeader, "req-42")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.ExpectedStatus {
+				t.Fatalf("expected status code: %d, got: %d", tc.ExpectedStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("expected content type: %s, got: %s", problemContentType, ct)
+			}
+			var p problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("failed to decode the problem %q: %v", w.Body.String(), err)
+			}
+			if p.Code != tc.ExpectedCode || p.RequestID != "req-42" {
+				t.Errorf("expected code %s and request ID req-42, got: %s and %q", tc.ExpectedCode, p.Code, p.RequestID)
+			}
+		})
+	}
+}
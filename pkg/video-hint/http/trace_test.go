@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/seggga/postgres/pkg/tracing"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	db := &traceDBMock{dbMock: dbMock{t: t, expectedSubstring: "repudiandae"}}
+	h := NewHandler(db, Config{
+		PageLimits:          service.DefaultPageLimits,
+		SimilarityThreshold: service.DefaultSimilarityThreshold,
+	})
+	r := mux.NewRouter()
+	r.Use(otelmux.Middleware("video-hint", otelmux.WithTracerProvider(tp), otelmux.WithPropagators(tracing.Propagator())))
+	r.HandleFunc("/video/{captionSubstring}", func(w http.ResponseWriter, r *http.Request) {
+		h.GetVideosByCaption(w, r, mux.Vars(r)["captionSubstring"])
+	}).Methods("GET")
+
+	req := httptest.NewRequest(http.MethodGet, "/video/repudiandae", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929b0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code: %d, got: %d", http.StatusOK, w.Code)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	route, ok := spans["/video/{captionSubstring}"]
+	if !ok {
+		t.Fatalf("expected a span of the route, got: %v", spans)
+	}
+	search, ok := spans["service.GetVideosByCaption"]
+	if !ok {
+		t.Fatalf("expected a span of the search, got: %v", spans)
+	}
+
+	if id := route.SpanContext.TraceID().String(); id != "4bf92f3577b34da6a3ce929b0e0e4736" {
+		t.Errorf("expected the route to join the caller trace, got trace ID: %s", id)
+	}
+	if id := route.Parent.SpanID().String(); id != "00f067aa0ba902b7" || !route.Parent.IsRemote() {
+		t.Errorf("expected the route to be a child of the caller span, got parent: %s", id)
+	}
+	if search.Parent.SpanID() != route.SpanContext.SpanID() {
+		t.Errorf("expected the search to be a child of the route span")
+	}
+	if search.SpanContext.TraceID() != route.SpanContext.TraceID() {
+		t.Errorf("expected the search to share the trace of the route")
+	}
+	if db.span.SpanID() != search.SpanContext.SpanID() {
+		t.Errorf("expected the DB to be queried within the search span")
+	}
+}
+
+// traceDBMock remembers the span the search was running in
+type traceDBMock struct {
+	dbMock
+
+	span trace.SpanContext
+}
+
+func (db *traceDBMock) GetVideosByCaption(ctx context.Context, q *storage.CaptionQuery) ([]*storage.FoundVideo, error) {
+	db.span = trace.SpanContextFromContext(ctx)
+	return db.dbMock.GetVideosByCaption(ctx, q)
+}
@@ -0,0 +1,176 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+// dateLayout is the format of the birthdays in requests and responses
+const dateLayout = "2006-01-02"
+
+// userRequest is the body of the user registration
+type userRequest struct {
+	Name     string  `json:"name"`
+	Email    string  `json:"email"`
+	Login    string  `json:"login"`
+	Birthday string  `json:"birthday"`
+	About    *string `json:"about"`
+}
+
+// userPatch is the body of the user update, absent fields are kept as they are and a null about clears it
+type userPatch struct {
+	Name     *string        `json:"name"`
+	Email    *string        `json:"email"`
+	Login    *string        `json:"login"`
+	Birthday *string        `json:"birthday"`
+	About    optionalString `json:"about"`
+}
+
+// optionalString tells an absent field from a null one, Value is nil for both
+type optionalString struct {
+	Set   bool
+	Value *string
+}
+
+func (s *optionalString) UnmarshalJSON(data []byte) error {
+	s.Set = true
+	return json.Unmarshal(data, &s.Value)
+}
+
+type userResponse struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Email    string  `json:"email"`
+	Login    string  `json:"login"`
+	Birthday string  `json:"birthday"`
+	About    *string `json:"about"`
+}
+
+type userPageResponse struct {
+	Users      []*userResponse `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	birthday, err := parseBirthday(req.Birthday)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	u, err := service.CreateUser(r.Context(), h.db, &storage.User{
+		Name:     req.Name,
+		Email:    req.Email,
+		Login:    req.Login,
+		Birthday: birthday,
+		About:    req.About,
+	})
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", u.ID))
+	writeJSON(w, r, http.StatusCreated, newUserResponse(u))
+}
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request, userID string) {
+	id, err := parseID(userID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	u, err := service.GetUser(r.Context(), h.db, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, newUserResponse(u))
+}
+
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request, userID string) {
+	id, err := parseID(userID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var patch userPatch
+	if err := decodeBody(w, r, &patch); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	upd := &storage.UserUpdate{
+		Name:       patch.Name,
+		Email:      patch.Email,
+		Login:      patch.Login,
+		About:      patch.About.Value,
+		ClearAbout: patch.About.Set && patch.About.Value == nil,
+	}
+	if patch.Birthday != nil {
+		birthday, err := parseBirthday(*patch.Birthday)
+		if err != nil {
+			writeProblem(w, r, err)
+			return
+		}
+		upd.Birthday = &birthday
+	}
+	u, err := service.UpdateUser(r.Context(), h.db, id, upd)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, newUserResponse(u))
+}
+
+// ListUsers pages through users in the registration order, see limit and cursor query parameters
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := parseIntParam(query, "limit")
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	page, err := service.ListUsers(r.Context(), h.db, limit, query.Get("cursor"), h.cfg.PageLimits)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	resp := &userPageResponse{
+		Users:      make([]*userResponse, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+	}
+	for _, u := range page.Users {
+		resp.Users = append(resp.Users, newUserResponse(u))
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+func newUserResponse(u *storage.User) *userResponse {
+	return &userResponse{
+		ID:       u.ID,
+		Name:     u.Name,
+		Email:    u.Email,
+		Login:    u.Login,
+		Birthday: u.Birthday.Format(dateLayout),
+		About:    u.About,
+	}
+}
+
+func parseBirthday(val string) (time.Time, error) {
+	birthday, err := time.Parse(dateLayout, val)
+	if err != nil {
+		return time.Time{}, &service.FieldErrors{
+			Kind:   service.ErrIncorrectUser,
+			Fields: []service.FieldError{{Field: "birthday", Message: "must be a date formatted as YYYY-MM-DD"}},
+		}
+	}
+	return birthday, nil
+}
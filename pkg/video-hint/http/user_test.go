@@ -0,0 +1,238 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const validUserBody = `{"name": "Ivan", "email": "ivan@example.com", "login": "ivan", "birthday": "1990-05-01"}`
+
+func TestUserHandlers(t *testing.T) {
+	cases := []struct {
+		Name             string
+		Method           string
+		Path             string
+		Body             string
+		MockErr          error
+		ExpectedRespCode int
+		ExpectedFields   []string
+	}{
+		{
+			Name:             "register",
+			Method:           "POST",
+			Path:             "/users",
+			Body:             validUserBody,
+			ExpectedRespCode: http.StatusCreated,
+		},
+		{
+			Name:             "register malformed birthday",
+			Method:           "POST",
+			Path:             "/users",
+			Body:             `{"name": "Ivan", "email": "ivan@example.com", "login": "ivan", "birthday": "01.05.1990"}`,
+			ExpectedRespCode: http.StatusBadRequest,
+			ExpectedFields:   []string{"birthday"},
+		},
+		{
+			Name:             "register incorrect fields",
+			Method:           "POST",
+			Path:             "/users",
+			Body:             `{"name": "", "email": "ivan", "login": "ivan", "birthday": "1990-05-01"}`,
+			ExpectedRespCode: http.StatusBadRequest,
+			ExpectedFields:   []string{"name", "email"},
+		},
+		{
+			Name:             "register taken email",
+			Method:           "POST",
+			Path:             "/users",
+			Body:             validUserBody,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "users_email_key", Field: "email"},
+			ExpectedRespCode: http.StatusConflict,
+			ExpectedFields:   []string{"email"},
+		},
+		{
+			Name:             "register taken login",
+			Method:           "POST",
+			Path:             "/users",
+			Body:             validUserBody,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "users_login_key", Field: "login"},
+			ExpectedRespCode: http.StatusConflict,
+			ExpectedFields:   []string{"login"},
+		},
+		{
+			Name:             "get",
+			Method:           "GET",
+			Path:             "/users/1",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "get missing",
+			Method:           "GET",
+			Path:             "/users/1",
+			MockErr:          fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedRespCode: http.StatusNotFound,
+		},
+		{
+			Name:             "update taken login",
+			Method:           "PATCH",
+			Path:             "/users/1",
+			Body:             `{"login": "petr"}`,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "users_login_key", Field: "login"},
+			ExpectedRespCode: http.StatusConflict,
+			ExpectedFields:   []string{"login"},
+		},
+		{
+			Name:             "update birthday",
+			Method:           "PATCH",
+			Path:             "/users/1",
+			Body:             `{"birthday": "1991-01-01"}`,
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "list",
+			Method:           "GET",
+			Path:             "/users?limit=10",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "list incorrect limit",
+			Method:           "GET",
+			Path:             "/users?limit=1000",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, tc.Path, strings.NewReader(tc.Body))
+			if err != nil {
+				t.Errorf("failed to create an http request: %v", err)
+				return
+			}
+			h := NewHandler(&userDBMock{err: tc.MockErr}, Config{
+				PageLimits:          service.DefaultPageLimits,
+				SimilarityThreshold: service.DefaultSimilarityThreshold,
+			})
+
+			handler := mux.NewRouter()
+			handler.HandleFunc("/users", h.RegisterUser).Methods("POST")
+			handler.HandleFunc("/users", h.ListUsers).Methods("GET")
+			handler.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.GetUser(w, r, mux.Vars(r)["id"])
+			}).Methods("GET")
+			handler.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.UpdateUser(w, r, mux.Vars(r)["id"])
+			}).Methods("PATCH")
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.ExpectedRespCode {
+				t.Fatalf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+			if len(tc.ExpectedFields) == 0 {
+				return
+			}
+			var body problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode the error body %q: %v", rr.Body.String(), err)
+			}
+			fields := make([]string, 0, len(body.Errors))
+			for _, f := range body.Errors {
+				fields = append(fields, f.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.ExpectedFields, ",") {
+				t.Errorf("expected errors of fields %v, got %v", tc.ExpectedFields, fields)
+			}
+		})
+	}
+}
+
+func TestUpdateUserAbout(t *testing.T) {
+	cases := []struct {
+		Name          string
+		Body          string
+		ExpectedAbout string
+		ExpectedClear bool
+	}{
+		{
+			Name: "absent",
+			Body: `{"login": "petr"}`,
+		},
+		{
+			Name:          "null",
+			Body:          `{"about": null}`,
+			ExpectedClear: true,
+		},
+		{
+			Name:          "set",
+			Body:          `{"about": "hello"}`,
+			ExpectedAbout: "hello",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			db := &userDBMock{}
+			h := NewHandler(db, Config{PageLimits: service.DefaultPageLimits})
+			req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(tc.Body))
+			rr := httptest.NewRecorder()
+			h.UpdateUser(rr, req, "1")
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected code: %d, got: %d", http.StatusOK, rr.Code)
+			}
+			about := ""
+			if db.update.About != nil {
+				about = *db.update.About
+			}
+			if about != tc.ExpectedAbout || db.update.ClearAbout != tc.ExpectedClear {
+				t.Errorf("expected about %q and clear %v, got: %q and %v", tc.ExpectedAbout, tc.ExpectedClear, about, db.update.ClearAbout)
+			}
+		})
+	}
+}
+
+// userDBMock fails every user call with err and keeps the last update
+type userDBMock struct {
+	storage.DB
+
+	err    error
+	update *storage.UserUpdate
+}
+
+func (db *userDBMock) CreateUser(ctx context.Context, u *storage.User) (*storage.User, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	created := *u
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *userDBMock) GetUser(ctx context.Context, id int) (*storage.User, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.User{ID: id}, nil
+}
+
+func (db *userDBMock) UpdateUser(ctx context.Context, id int, upd *storage.UserUpdate) (*storage.User, error) {
+	db.update = upd
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.User{ID: id}, nil
+}
+
+func (db *userDBMock) ListUsers(ctx context.Context, q *storage.UserQuery) ([]*storage.User, error) {
+	return []*storage.User{{ID: 1}}, db.err
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/seggga/postgres/pkg/logging"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+// maxBodySize bounds the JSON bodies accepted by the handlers
+const maxBodySize = 1 << 20
+
+// videoRequest is the body of the video creation
+type videoRequest struct {
+	UserID      int    `json:"user_id"`
+	Location    string `json:"location"`
+	URI         string `json:"uri"`
+	RES         string `json:"res"`
+	Caption     string `json:"caption"`
+	Description string `json:"description"`
+}
+
+// videoPatch is the body of the video update, absent fields are kept as they are
+type videoPatch struct {
+	Location    *string `json:"location"`
+	URI         *string `json:"uri"`
+	RES         *string `json:"res"`
+	Caption     *string `json:"caption"`
+	Description *string `json:"description"`
+}
+
+func (h *Handler) CreateVideo(w http.ResponseWriter, r *http.Request) {
+	var req videoRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	v, err := service.CreateVideo(r.Context(), h.db, &storage.Video{
+		UserID:      req.UserID,
+		Location:    req.Location,
+		URI:         req.URI,
+		RES:         req.RES,
+		Caption:     req.Caption,
+		Description: req.Description,
+	})
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/videos/%d", v.ID))
+	writeJSON(w, r, http.StatusCreated, v)
+}
+
+func (h *Handler) GetVideo(w http.ResponseWriter, r *http.Request, videoID string) {
+	id, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	v, err := service.GetVideo(r.Context(), h.db, id)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, v)
+}
+
+func (h *Handler) UpdateVideo(w http.ResponseWriter, r *http.Request, videoID string) {
+	id, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	var patch videoPatch
+	if err := decodeBody(w, r, &patch); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	v, err := service.UpdateVideo(r.Context(), h.db, id, &storage.VideoUpdate{
+		Location:    patch.Location,
+		URI:         patch.URI,
+		RES:         patch.RES,
+		Caption:     patch.Caption,
+		Description: patch.Description,
+	})
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, v)
+}
+
+func (h *Handler) DeleteVideo(w http.ResponseWriter, r *http.Request, videoID string) {
+	id, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	if err := service.DeleteVideo(r.Context(), h.db, id); err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseID(val string) (int, error) {
+	id, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fieldError("id", "must be an integer, got %q", val)
+	}
+	return id, nil
+}
+
+// decodeBody reads a JSON object of a known shape, unknown fields are rejected
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return fmt.Errorf("%w: failed to decode the request body: %v", errIncorrectRequest, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w: the request body must hold a single JSON object", errIncorrectRequest)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		writeProblem(w, r, fmt.Errorf("failed to serialize the response to JSON: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		logging.FromContext(r.Context()).Errorf("failed to write the response body: %v", err)
+	}
+}
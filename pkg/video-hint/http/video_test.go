@@ -0,0 +1,204 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const validVideoBody = `{"user_id": 1, "location": "/srv/videos/1.mp4", "uri": "https://videos.example.com/1",
+	"res": "720p", "caption": "repudiandae"}`
+
+func TestVideoHandlers(t *testing.T) {
+	cases := []struct {
+		Name             string
+		Method           string
+		Path             string
+		Body             string
+		MockErr          error
+		ExpectedRespCode int
+	}{
+		{
+			Name:             "create",
+			Method:           "POST",
+			Path:             "/videos",
+			Body:             validVideoBody,
+			ExpectedRespCode: http.StatusCreated,
+		},
+		{
+			Name:             "create malformed body",
+			Method:           "POST",
+			Path:             "/videos",
+			Body:             `{"user_id": "one"}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "create unknown field",
+			Method:           "POST",
+			Path:             "/videos",
+			Body:             `{"user_id": 1, "likes": 10}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "create invalid video",
+			Method:           "POST",
+			Path:             "/videos",
+			Body:             `{"user_id": 1, "location": "/srv/videos/1.mp4", "uri": "https://videos.example.com/1", "res": "4k", "caption": "c"}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "create duplicate",
+			Method:           "POST",
+			Path:             "/videos",
+			Body:             validVideoBody,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "videos_uri_key", Field: "uri"},
+			ExpectedRespCode: http.StatusConflict,
+		},
+		{
+			Name:             "get",
+			Method:           "GET",
+			Path:             "/videos/1",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "get non-numeric id",
+			Method:           "GET",
+			Path:             "/videos/one",
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "get missing",
+			Method:           "GET",
+			Path:             "/videos/1",
+			MockErr:          fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedRespCode: http.StatusNotFound,
+		},
+		{
+			Name:             "get timeout",
+			Method:           "GET",
+			Path:             "/videos/1",
+			MockErr:          fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedRespCode: http.StatusGatewayTimeout,
+		},
+		{
+			Name:             "update",
+			Method:           "PATCH",
+			Path:             "/videos/1",
+			Body:             `{"caption": "quis"}`,
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "update nothing",
+			Method:           "PATCH",
+			Path:             "/videos/1",
+			Body:             `{}`,
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "update duplicate uri",
+			Method:           "PATCH",
+			Path:             "/videos/1",
+			Body:             `{"uri": "https://videos.example.com/2"}`,
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "videos_uri_key", Field: "uri"},
+			ExpectedRespCode: http.StatusConflict,
+		},
+		{
+			Name:             "delete",
+			Method:           "DELETE",
+			Path:             "/videos/1",
+			ExpectedRespCode: http.StatusNoContent,
+		},
+		{
+			Name:             "delete referenced",
+			Method:           "DELETE",
+			Path:             "/videos/1",
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_video_id"},
+			ExpectedRespCode: http.StatusConflict,
+		},
+		{
+			Name:             "delete db failure",
+			Method:           "DELETE",
+			Path:             "/videos/1",
+			MockErr:          fmt.Errorf("connection reset"),
+			ExpectedRespCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, tc.Path, strings.NewReader(tc.Body))
+			if err != nil {
+				t.Errorf("failed to create an http request: %v", err)
+				return
+			}
+			h := NewHandler(&videoDBMock{err: tc.MockErr}, Config{
+				PageLimits:          service.DefaultPageLimits,
+				SimilarityThreshold: service.DefaultSimilarityThreshold,
+			})
+
+			handler := mux.NewRouter()
+			handler.HandleFunc("/videos", h.CreateVideo).Methods("POST")
+			handler.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.GetVideo(w, r, mux.Vars(r)["id"])
+			}).Methods("GET")
+			handler.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.UpdateVideo(w, r, mux.Vars(r)["id"])
+			}).Methods("PATCH")
+			handler.HandleFunc("/videos/{id}", func(w http.ResponseWriter, r *http.Request) {
+				h.DeleteVideo(w, r, mux.Vars(r)["id"])
+			}).Methods("DELETE")
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+// videoDBMock fails every video call with err
+type videoDBMock struct {
+	storage.DB
+
+	err error
+}
+
+func (db *videoDBMock) CreateVideo(ctx context.Context, v *storage.Video) (*storage.Video, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	created := *v
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *videoDBMock) GetVideo(ctx context.Context, id int) (*storage.Video, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *videoDBMock) UpdateVideo(ctx context.Context, id int, upd *storage.VideoUpdate) (*storage.Video, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *videoDBMock) DeleteVideo(ctx context.Context, id int) error {
+	return db.err
+}
+
+func (db *videoDBMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
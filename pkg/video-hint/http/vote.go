@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+type voteFunc func(ctx context.Context, db storage.DB, userID int, videoID int) (*service.VideoVotes, error)
+
+// Like, Dislike and Unvote respond with the vote counts of the video, repeating them changes nothing
+func (h *Handler) Like(w http.ResponseWriter, r *http.Request, videoID string) {
+	h.vote(w, r, videoID, service.Like)
+}
+
+func (h *Handler) Dislike(w http.ResponseWriter, r *http.Request, videoID string) {
+	h.vote(w, r, videoID, service.Dislike)
+}
+
+func (h *Handler) Unvote(w http.ResponseWriter, r *http.Request, videoID string) {
+	h.vote(w, r, videoID, service.Unvote)
+}
+
+func (h *Handler) vote(w http.ResponseWriter, r *http.Request, videoID string, vote voteFunc) {
+	vID, err := parseID(videoID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	userID, err := parseUserID(r)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	votes, err := vote(r.Context(), h.db, userID, vID)
+	if err != nil {
+		writeProblem(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, votes)
+}
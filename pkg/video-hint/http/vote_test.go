@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/seggga/postgres/pkg/video-hint/service"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestVoteHandlers(t *testing.T) {
+	cases := []struct {
+		Name             string
+		Method           string
+		Path             string
+		UserID           string
+		MockErr          error
+		ExpectedRespCode int
+	}{
+		{
+			Name:             "like",
+			Method:           "PUT",
+			Path:             "/videos/1/like",
+			UserID:           "1",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "dislike",
+			Method:           "PUT",
+			Path:             "/videos/1/dislike",
+			UserID:           "1",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "unvote",
+			Method:           "DELETE",
+			Path:             "/videos/1/vote",
+			UserID:           "1",
+			ExpectedRespCode: http.StatusOK,
+		},
+		{
+			Name:             "anonymous like",
+			Method:           "PUT",
+			Path:             "/videos/1/like",
+			ExpectedRespCode: http.StatusUnauthorized,
+		},
+		{
+			Name:             "like of missing video",
+			Method:           "PUT",
+			Path:             "/videos/1/like",
+			UserID:           "1",
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_video_id", Field: "video_id"},
+			ExpectedRespCode: http.StatusNotFound,
+		},
+		{
+			Name:             "like of missing user",
+			Method:           "PUT",
+			Path:             "/videos/1/like",
+			UserID:           "1",
+			MockErr:          &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_user_id", Field: "user_id"},
+			ExpectedRespCode: http.StatusBadRequest,
+		},
+		{
+			Name:             "db failure",
+			Method:           "DELETE",
+			Path:             "/videos/1/vote",
+			UserID:           "1",
+			MockErr:          fmt.Errorf("connection reset"),
+			ExpectedRespCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.Method, tc.Path, nil)
+			if err != nil {
+				t.Errorf("failed to create an http request: %v", err)
+				return
+			}
+			if tc.UserID != "" {
+				req.Header.Set(UserIDHeader, tc.UserID)
+			}
+			h := NewHandler(&voteDBMock{err: tc.MockErr}, Config{
+				PageLimits:          service.DefaultPageLimits,
+				SimilarityThreshold: service.DefaultSimilarityThreshold,
+			})
+
+			handler := mux.NewRouter()
+			handler.HandleFunc("/videos/{id}/like", func(w http.ResponseWriter, r *http.Request) {
+				h.Like(w, r, mux.Vars(r)["id"])
+			}).Methods("PUT")
+			handler.HandleFunc("/videos/{id}/dislike", func(w http.ResponseWriter, r *http.Request) {
+				h.Dislike(w, r, mux.Vars(r)["id"])
+			}).Methods("PUT")
+			handler.HandleFunc("/videos/{id}/vote", func(w http.ResponseWriter, r *http.Request) {
+				h.Unvote(w, r, mux.Vars(r)["id"])
+			}).Methods("DELETE")
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tc.ExpectedRespCode {
+				t.Errorf("expected code: %d, got: %d", tc.ExpectedRespCode, rr.Code)
+			}
+		})
+	}
+}
+
+// voteDBMock fails every vote with err
+type voteDBMock struct {
+	storage.DB
+
+	err error
+}
+
+func (db *voteDBMock) Vote(ctx context.Context, userID int, videoID int, thumbUp bool) error {
+	return db.err
+}
+
+func (db *voteDBMock) Unvote(ctx context.Context, userID int, videoID int) error {
+	return db.err
+}
+
+func (db *voteDBMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+var (
+	ErrIncorrectComment = newError(KindValidation, "incorrect_comment", "got an incorrect comment")
+	ErrForbidden        = newError(KindForbidden, "forbidden", "the action is not allowed to the user")
+)
+
+// MaxCommentLen bounds the length of a comment body in characters
+const MaxCommentLen = 2000
+
+// CommentRequest describes one page of the comments of a video
+type CommentRequest struct {
+	VideoID int
+	Order   string
+	Limit   int
+	Cursor  string
+}
+
+// CommentPage is a page of comments, NextCursor is empty on the last page
+type CommentPage struct {
+	Comments   []*storage.Comment `json:"comments"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// PostComment adds a comment of the user to the video
+func PostComment(ctx context.Context, db storage.DB, c *storage.Comment) (*storage.Comment, error) {
+	if c.UserID <= 0 {
+		return nil, fmt.Errorf("%w: user_id must be positive, got %d", ErrIncorrectComment, c.UserID)
+	}
+	if c.VideoID <= 0 {
+		return nil, fmt.Errorf("%w: video %d", ErrNotFound, c.VideoID)
+	}
+	if err := validateCommentBody(c.Body); err != nil {
+		return nil, err
+	}
+	created, err := db.CreateComment(ctx, c)
+	if err != nil {
+		var cErr *storage.ConstraintError
+		if errors.As(err, &cErr) && cErr.Field == "video_id" {
+			return nil, fmt.Errorf("%w: video %d: %v", ErrNotFound, c.VideoID, err)
+		}
+		if errors.Is(err, storage.ErrConstraint) {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectComment, err)
+		}
+		return nil, mapDBError(err)
+	}
+	return created, nil
+}
+
+// ListComments returns a page of the comments of an existing video ordered by creation time
+func ListComments(ctx context.Context, db storage.DB, req *CommentRequest, limits PageLimits) (*CommentPage, error) {
+	if req.VideoID <= 0 {
+		return nil, fmt.Errorf("%w: video %d", ErrNotFound, req.VideoID)
+	}
+	_, asc, err := parseSort(string(storage.SortCreatedAt), req.Order)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = limits.Default
+	}
+	if limit < 0 || limit > limits.Max {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d, got %d", ErrIncorrectPage, limits.Max, req.Limit)
+	}
+	q := &storage.CommentQuery{
+		VideoID: req.VideoID,
+		Asc:     asc,
+		// one extra row tells whether there is a next page
+		Limit: limit + 1,
+	}
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectPage, err)
+		}
+		if cursor.Sort != storage.SortCreatedAt || cursor.Asc != asc {
+			return nil, fmt.Errorf("%w: the cursor was issued for a different sort order", ErrIncorrectPage)
+		}
+		q.After = cursor.After
+	}
+	comments, err := db.ListComments(ctx, q)
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	// an empty page is all a missing video gets, CountComments answers it with ErrNotFound
+	if len(comments) == 0 {
+		if _, err := db.GetVideo(ctx, req.VideoID); err != nil {
+			return nil, mapDBError(err)
+		}
+	}
+	page := &CommentPage{
+		Comments: comments,
+	}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		last := page.Comments[limit-1]
+		page.NextCursor = encodeCursor(&pageCursor{
+			Sort: storage.SortCreatedAt,
+			Asc:  asc,
+			After: &storage.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.ID,
+			},
+		})
+	}
+	return page, nil
+}
+
+// EditComment replaces the body of a comment, only its author may edit it
+func EditComment(ctx context.Context, db storage.DB, id int, userID int, body string) (*storage.Comment, error) {
+	if err := validateCommentBody(body); err != nil {
+		return nil, err
+	}
+	if err := checkCommentAuthor(ctx, db, id, userID); err != nil {
+		return nil, err
+	}
+	c, err := db.UpdateComment(ctx, id, userID, body)
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	return c, nil
+}
+
+// DeleteComment removes a comment, only its author may delete it
+func DeleteComment(ctx context.Context, db storage.DB, id int, userID int) error {
+	if err := checkCommentAuthor(ctx, db, id, userID); err != nil {
+		return err
+	}
+	if err := db.DeleteComment(ctx, id, userID); err != nil {
+		return mapDBError(err)
+	}
+	return nil
+}
+
+// CountComments returns the number of comments of an existing video
+func CountComments(ctx context.Context, db storage.DB, videoID int) (int, error) {
+	if videoID <= 0 {
+		return 0, fmt.Errorf("%w: video %d", ErrNotFound, videoID)
+	}
+	count, err := db.CountComments(ctx, videoID)
+	if err != nil {
+		return 0, mapDBError(err)
+	}
+	return count, nil
+}
+
+// checkCommentAuthor tells a missing comment from someone else's one
+func checkCommentAuthor(ctx context.Context, db storage.DB, id int, userID int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: comment %d", ErrNotFound, id)
+	}
+	c, err := db.GetComment(ctx, id)
+	if err != nil {
+		return mapDBError(err)
+	}
+	if c.UserID != userID {
+		return fmt.Errorf("%w: comment %d belongs to another user", ErrForbidden, id)
+	}
+	return nil
+}
+
+func validateCommentBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("%w: body must not be empty", ErrIncorrectComment)
+	}
+	if n := utf8.RuneCountInString(body); n > MaxCommentLen {
+		return fmt.Errorf("%w: body must be at most %d characters long, got %d", ErrIncorrectComment, MaxCommentLen, n)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestPostComment(t *testing.T) {
+	cases := []struct {
+		Name        string
+		Comment     *storage.Comment
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Name:    "valid comment",
+			Comment: &storage.Comment{UserID: 1, VideoID: 1, Body: "nice"},
+		},
+		{
+			Name:        "empty body",
+			Comment:     &storage.Comment{UserID: 1, VideoID: 1, Body: " \n"},
+			ExpectedErr: ErrIncorrectComment,
+		},
+		{
+			Name:    "longest body",
+			Comment: &storage.Comment{UserID: 1, VideoID: 1, Body: strings.Repeat("ы", MaxCommentLen)},
+		},
+		{
+			Name:        "too long body",
+			Comment:     &storage.Comment{UserID: 1, VideoID: 1, Body: strings.Repeat("ы", MaxCommentLen+1)},
+			ExpectedErr: ErrIncorrectComment,
+		},
+		{
+			Name:        "missing video",
+			Comment:     &storage.Comment{UserID: 1, VideoID: 1, Body: "nice"},
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "comments_fk_video_id", Field: "video_id"},
+			ExpectedErr: ErrNotFound,
+		},
+		{
+			Name:        "missing user",
+			Comment:     &storage.Comment{UserID: 1, VideoID: 1, Body: "nice"},
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "comments_fk_user_id", Field: "user_id"},
+			ExpectedErr: ErrIncorrectComment,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := PostComment(context.Background(), &commentDBMock{err: tc.MockErr}, tc.Comment)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestListComments(t *testing.T) {
+	start := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	comments := make([]*storage.Comment, 5)
+	for i := range comments {
+		comments[i] = &storage.Comment{ID: i + 1, VideoID: 1, CreatedAt: start.Add(time.Duration(i) * time.Hour)}
+	}
+	mock := &commentDBMock{comments: comments}
+
+	page, err := ListComments(context.Background(), mock, &CommentRequest{VideoID: 1, Order: OrderAsc, Limit: 2}, DefaultPageLimits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Comments) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 comments and a next cursor, got %d comments and cursor %q", len(page.Comments), page.NextCursor)
+	}
+	req := &CommentRequest{VideoID: 1, Order: OrderAsc, Limit: 2, Cursor: page.NextCursor}
+	if _, err := ListComments(context.Background(), mock, req, DefaultPageLimits); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.query.After == nil || mock.query.After.ID != 2 || !mock.query.After.CreatedAt.Equal(comments[1].CreatedAt) {
+		t.Errorf("expected the page to start after comment 2, got %+v", mock.query.After)
+	}
+	if !mock.query.Asc || mock.query.Limit != 3 {
+		t.Errorf("expected an ascending query of 3 comments, got %+v", mock.query)
+	}
+
+	req.Order = OrderDesc
+	if _, err := ListComments(context.Background(), mock, req, DefaultPageLimits); !errors.Is(err, ErrIncorrectPage) {
+		t.Errorf("expected %v for a cursor of another order, got %v", ErrIncorrectPage, err)
+	}
+	req = &CommentRequest{VideoID: 1, Order: "random"}
+	if _, err := ListComments(context.Background(), mock, req, DefaultPageLimits); !errors.Is(err, ErrIncorrectSort) {
+		t.Errorf("expected %v for an unknown order, got %v", ErrIncorrectSort, err)
+	}
+
+	empty := &commentDBMock{}
+	if page, err := ListComments(context.Background(), empty, &CommentRequest{VideoID: 1}, DefaultPageLimits); err != nil || len(page.Comments) != 0 {
+		t.Errorf("expected an empty page of an existing video, got %v and %v", page, err)
+	}
+	empty.err = fmt.Errorf("%w: no rows", storage.ErrNotFound)
+	if _, err := ListComments(context.Background(), empty, &CommentRequest{VideoID: 1}, DefaultPageLimits); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v for a missing video, got %v", ErrNotFound, err)
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	cases := []struct {
+		Name        string
+		UserID      int
+		Body        string
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Name:   "author",
+			UserID: 1,
+			Body:   "edited",
+		},
+		{
+			Name:        "another user",
+			UserID:      2,
+			Body:        "edited",
+			ExpectedErr: ErrForbidden,
+		},
+		{
+			Name:        "empty body",
+			UserID:      1,
+			Body:        "",
+			ExpectedErr: ErrIncorrectComment,
+		},
+		{
+			Name:        "missing comment",
+			UserID:      1,
+			Body:        "edited",
+			MockErr:     fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			mock := &commentDBMock{err: tc.MockErr, authorID: 1}
+			_, err := EditComment(context.Background(), mock, 1, tc.UserID, tc.Body)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+			if tc.ExpectedErr != nil && mock.changed {
+				t.Errorf("expected the comment to be kept")
+			}
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	mock := &commentDBMock{authorID: 1}
+	if err := DeleteComment(context.Background(), mock, 1, 2); !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected %v deleting a comment of another user, got %v", ErrForbidden, err)
+	}
+	if mock.changed {
+		t.Errorf("expected the comment of another user to be kept")
+	}
+	if err := DeleteComment(context.Background(), mock, 1, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !mock.changed {
+		t.Errorf("expected the comment to be deleted")
+	}
+}
+
+// commentDBMock fails every comment call with err, the comments it gets belong to authorID
+type commentDBMock struct {
+	storage.DB
+
+	err      error
+	authorID int
+	comments []*storage.Comment
+	query    *storage.CommentQuery
+	changed  bool
+}
+
+func (db *commentDBMock) CreateComment(ctx context.Context, c *storage.Comment) (*storage.Comment, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	created := *c
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *commentDBMock) GetComment(ctx context.Context, id int) (*storage.Comment, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Comment{ID: id, UserID: db.authorID}, nil
+}
+
+func (db *commentDBMock) GetVideo(ctx context.Context, id int) (*storage.Video, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *commentDBMock) ListComments(ctx context.Context, q *storage.CommentQuery) ([]*storage.Comment, error) {
+	db.query = q
+	comments := make([]*storage.Comment, 0, q.Limit)
+	for _, c := range db.comments {
+		if (q.After == nil || c.ID > q.After.ID) && len(comments) < q.Limit {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (db *commentDBMock) UpdateComment(ctx context.Context, id int, userID int, body string) (*storage.Comment, error) {
+	db.changed = true
+	return &storage.Comment{ID: id, UserID: userID, Body: body}, nil
+}
+
+func (db *commentDBMock) DeleteComment(ctx context.Context, id int, userID int) error {
+	db.changed = true
+	return nil
+}
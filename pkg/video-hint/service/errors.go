@@ -0,0 +1,58 @@
+package service
+
+import "errors"
+
+// Kind classifies the service errors for the transports
+type Kind int
+
+const (
+	// KindInternal is a failure the caller can do nothing about
+	KindInternal Kind = iota
+	// KindValidation is an incorrect request, FieldErrors tells the incorrect fields
+	KindValidation
+	// KindUnauthenticated is a request that does not tell the acting user
+	KindUnauthenticated
+	// KindForbidden is an action the user is not allowed to take
+	KindForbidden
+	// KindNotFound is a request to an object that does not exist
+	KindNotFound
+	// KindConflict is a change conflicting with the stored objects
+	KindConflict
+	// KindUnavailable is a DB failure, the request may succeed later
+	KindUnavailable
+	// KindTimeout is a request canceled or out of time
+	KindTimeout
+	// KindMethodNotAllowed is a request to an object that does not support its method
+	KindMethodNotAllowed
+)
+
+// Error is a sentinel service error, Code names it to the clients and does not change
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind Kind, code string, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// AsError returns the outermost service error of err, ErrInternal if there is none
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal
+}
+
+// ErrInternal stands for the errors that are not service errors
+var ErrInternal = newError(KindInternal, "internal", "the request failed")
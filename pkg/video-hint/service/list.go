@@ -2,24 +2,345 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
 
 var (
-	ErrIncorrectCaptionSubstring = fmt.Errorf("got an incorrect caption substring")
-	ErrDBRequestFailed           = fmt.Errorf("a request to DB failed")
+	ErrIncorrectCaptionSubstring = newError(KindValidation, "incorrect_caption_substring", "got an incorrect caption substring")
+	ErrIncorrectPage             = newError(KindValidation, "incorrect_page", "got incorrect pagination parameters")
+	ErrIncorrectSort             = newError(KindValidation, "incorrect_sort", "got incorrect sorting parameters")
+	ErrIncorrectMode             = newError(KindValidation, "incorrect_mode", "got an incorrect search mode")
+	ErrIncorrectThreshold        = newError(KindValidation, "incorrect_threshold", "got an incorrect similarity threshold")
+	ErrDBRequestFailed           = newError(KindUnavailable, "db_unavailable", "a request to DB failed")
+	ErrTimeout                   = newError(KindTimeout, "timeout", "the request was canceled or timed out")
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const (
+	// ModeSubstring looks for the phrase in video captions
+	ModeSubstring = "substring"
+	// ModePattern uses the phrase as a LIKE pattern, the other modes match % and _ literally
+	ModePattern = "pattern"
+	// ModeFullText searches captions and descriptions, results carry highlighted snippets
+	ModeFullText = "fulltext"
 )
 
-func GetVideosByCaption(db storage.DB, captionSubstring string) ([]*storage.FoundVideo, error) {
-	if len(captionSubstring) == 0 {
+// PageLimits bounds the number of videos returned on one page
+type PageLimits struct {
+	Default int
+	Max     int
+}
+
+var DefaultPageLimits = PageLimits{
+	Default: 20,
+	Max:     100,
+}
+
+// DefaultSimilarityThreshold is the minimal similarity of captions found by the fuzzy search
+const DefaultSimilarityThreshold = 0.3
+
+// SearchRequest describes one page of the caption search, fulltext and fuzzy searches sort by relevance only
+type SearchRequest struct {
+	Phrase    string
+	Mode      string
+	Fuzzy     bool
+	Threshold float64
+	Sort      string
+	Order     string
+	Limit     int
+	Offset    int
+	Cursor    string
+}
+
+// SearchResult is a page of found videos, NextCursor is empty on the last page
+type SearchResult struct {
+	Videos     []*storage.FoundVideo `json:"videos"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// SearchObserver is told the number of videos on every page found
+type SearchObserver interface {
+	ObserveSearch(mode string, results int)
+}
+
+// ModeFuzzy names the trigram similarity search to a SearchObserver
+const ModeFuzzy = "fuzzy"
+
+const tracerName = "github.com/seggga/postgres/pkg/video-hint/service"
+
+// GetVideosByCaption finds a page of videos, obs may be nil
+func GetVideosByCaption(ctx context.Context, db storage.DB, req *SearchRequest, limits PageLimits, obs SearchObserver) (*SearchResult, error) {
+	ctx, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "service.GetVideosByCaption",
+		trace.WithAttributes(attribute.String("search.mode", searchMode(req))))
+	defer span.End()
+	res, err := getVideosByCaption(ctx, db, req, limits, obs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("search.results", len(res.Videos)))
+	return res, nil
+}
+
+func getVideosByCaption(ctx context.Context, db storage.DB, req *SearchRequest, limits PageLimits, obs SearchObserver) (*SearchResult, error) {
+	if len(req.Phrase) == 0 {
 		return nil, fmt.Errorf("%w: passed search phrase is empty", ErrIncorrectCaptionSubstring)
 	}
-	videos, err := db.GetVideosByCaption(context.Background(), strings.ToLower(captionSubstring))
+	q, err := composeCaptionQuery(req, limits)
 	if err != nil {
+		return nil, err
+	}
+	pageSize := q.Limit
+	// one extra row tells whether there is a next page
+	q.Limit++
+	var videos []*storage.FoundVideo
+	switch {
+	case req.Mode == ModeFullText:
+		videos, err = db.SearchVideos(ctx, &storage.TextQuery{
+			Phrase: req.Phrase,
+			Asc:    q.Asc,
+			Limit:  q.Limit,
+			Offset: q.Offset,
+		})
+	case req.Fuzzy:
+		videos, err = db.GetVideosByCaptionSimilarity(ctx, &storage.SimilarityQuery{
+			Phrase:    q.Phrase,
+			Threshold: req.Threshold,
+			Asc:       q.Asc,
+			Limit:     q.Limit,
+			Offset:    q.Offset,
+		})
+	default:
+		videos, err = db.GetVideosByCaption(ctx, q)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrQueryCanceled) {
+			return nil, fmt.Errorf("%w: failed to get videos by caption substring: %v", ErrTimeout, err)
+		}
 		return nil, fmt.Errorf("%w: failed to get videos by caption substring: %v", ErrDBRequestFailed, err)
 	}
-	return videos, nil
+	res := &SearchResult{
+		Videos: videos,
+	}
+	if len(videos) > pageSize {
+		res.Videos = videos[:pageSize]
+		next := &pageCursor{
+			Sort: q.Sort,
+			Asc:  q.Asc,
+		}
+		if q.Sort.Keyset() {
+			last := res.Videos[pageSize-1]
+			next.After = &storage.Cursor{
+				CreatedAt: last.CreatedAt,
+				ID:        last.ID,
+			}
+		} else {
+			next.Offset = q.Offset + pageSize
+		}
+		res.NextCursor = encodeCursor(next)
+	}
+	if err := fillFoundVotes(ctx, db, res.Videos); err != nil {
+		return nil, fmt.Errorf("failed to count votes of found videos: %w", mapDBError(err))
+	}
+	if obs != nil {
+		obs.ObserveSearch(searchMode(req), len(res.Videos))
+	}
+	return res, nil
+}
+
+// searchMode names the search run for req, composeCaptionQuery has validated the mode
+func searchMode(req *SearchRequest) string {
+	switch {
+	case req.Mode == ModeFullText:
+		return ModeFullText
+	case req.Fuzzy:
+		return ModeFuzzy
+	case req.Mode == "":
+		return ModeSubstring
+	default:
+		return req.Mode
+	}
+}
+
+// danglingEscape tells whether the pattern ends with a backslash escaping nothing
+func danglingEscape(pattern string) bool {
+	n := len(pattern) - len(strings.TrimRight(pattern, `\`))
+	return n%2 == 1
+}
+
+func composeCaptionQuery(req *SearchRequest, limits PageLimits) (*storage.CaptionQuery, error) {
+	sort, asc, err := parseSort(req.Sort, req.Order)
+	if err != nil {
+		return nil, err
+	}
+	switch req.Mode {
+	case "", ModeSubstring:
+	case ModePattern, ModeFullText:
+		if req.Fuzzy {
+			return nil, fmt.Errorf("%w: %s search can not be fuzzy", ErrIncorrectMode, req.Mode)
+		}
+		if req.Mode == ModePattern && sort == storage.SortRelevance {
+			return nil, fmt.Errorf("%w: %s search results can not be sorted by %s", ErrIncorrectSort, ModePattern, sort)
+		}
+		if req.Mode == ModePattern && danglingEscape(req.Phrase) {
+			return nil, fmt.Errorf("%w: the pattern must not end with an unescaped \\", ErrIncorrectCaptionSubstring)
+		}
+	default:
+		return nil, fmt.Errorf(
+			"%w: mode must be %s, %s or %s, got %q",
+			ErrIncorrectMode, ModeSubstring, ModePattern, ModeFullText, req.Mode,
+		)
+	}
+	if req.Fuzzy && (req.Threshold <= 0 || req.Threshold > 1) {
+		return nil, fmt.Errorf("%w: threshold must be greater than 0 and at most 1, got %v", ErrIncorrectThreshold, req.Threshold)
+	}
+	// the full-text and the fuzzy searches rank the results themselves
+	if req.Mode == ModeFullText || req.Fuzzy {
+		if req.Sort == "" {
+			sort = storage.SortRelevance
+		}
+		if sort != storage.SortRelevance {
+			return nil, fmt.Errorf("%w: full-text and fuzzy search results can only be sorted by %s", ErrIncorrectSort, storage.SortRelevance)
+		}
+	}
+	q := &storage.CaptionQuery{
+		Phrase:  strings.ToLower(req.Phrase),
+		Pattern: req.Mode == ModePattern,
+		Sort:    sort,
+		Asc:     asc,
+		Limit:   req.Limit,
+		Offset:  req.Offset,
+	}
+	if q.Limit == 0 {
+		q.Limit = limits.Default
+	}
+	if q.Limit < 0 || q.Limit > limits.Max {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d, got %d", ErrIncorrectPage, limits.Max, req.Limit)
+	}
+	if q.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative, got %d", ErrIncorrectPage, req.Offset)
+	}
+	if req.Cursor != "" {
+		if req.Offset != 0 {
+			return nil, fmt.Errorf("%w: offset and cursor are mutually exclusive", ErrIncorrectPage)
+		}
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectPage, err)
+		}
+		if cursor.Sort != q.Sort || cursor.Asc != q.Asc {
+			return nil, fmt.Errorf("%w: the cursor was issued for a different sort order", ErrIncorrectPage)
+		}
+		q.After = cursor.After
+		q.Offset = cursor.Offset
+	}
+	return q, nil
+}
+
+func parseSort(sort string, order string) (storage.SortField, bool, error) {
+	field := storage.SortField(sort)
+	switch field {
+	case "":
+		field = storage.SortCreatedAt
+	case storage.SortCreatedAt, storage.SortUpdatedAt, storage.SortRelevance, storage.SortLikes:
+	default:
+		return "", false, fmt.Errorf(
+			"%w: sort must be one of %s, %s, %s or %s, got %q",
+			ErrIncorrectSort, storage.SortCreatedAt, storage.SortUpdatedAt, storage.SortRelevance, storage.SortLikes, sort,
+		)
+	}
+	switch order {
+	case "", OrderDesc:
+		return field, false, nil
+	case OrderAsc:
+		return field, true, nil
+	default:
+		return "", false, fmt.Errorf("%w: order must be %s or %s, got %q", ErrIncorrectSort, OrderAsc, OrderDesc, order)
+	}
+}
+
+// pageCursor is the position of the next page: the last video for keyset sorts, an offset otherwise
+type pageCursor struct {
+	Sort   storage.SortField
+	Asc    bool
+	After  *storage.Cursor
+	Offset int
+}
+
+const cursorSep = "|"
+
+// encodeCursor packs the cursor into an opaque URL-safe string
+func encodeCursor(c *pageCursor) string {
+	dir := OrderDesc
+	if c.Asc {
+		dir = OrderAsc
+	}
+	parts := []string{string(c.Sort), dir}
+	if c.After != nil {
+		parts = append(parts, c.After.CreatedAt.UTC().Format(time.RFC3339Nano), strconv.Itoa(c.After.ID))
+	} else {
+		parts = append(parts, strconv.Itoa(c.Offset))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, cursorSep)))
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	parts := strings.Split(string(raw), cursorSep)
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, fmt.Errorf("malformed cursor %q", s)
+	}
+	sort, asc, err := parseSort(parts[0], parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor sort: %w", err)
+	}
+	c := &pageCursor{
+		Sort: sort,
+		Asc:  asc,
+	}
+	if len(parts) == 3 {
+		if sort.Keyset() {
+			return nil, fmt.Errorf("malformed cursor %q", s)
+		}
+		c.Offset, err = strconv.Atoi(parts[2])
+		if err != nil || c.Offset < 0 {
+			return nil, fmt.Errorf("malformed cursor offset %q", parts[2])
+		}
+		return c, nil
+	}
+	if !sort.Keyset() {
+		return nil, fmt.Errorf("malformed cursor %q", s)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor time: %w", err)
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor id: %w", err)
+	}
+	c.After = &storage.Cursor{
+		CreatedAt: createdAt,
+		ID:        id,
+	}
+	return c, nil
 }
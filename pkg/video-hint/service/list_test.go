@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
@@ -55,6 +56,13 @@ func TestGetVideosByCaption(t *testing.T) {
 			MockErr:        fmt.Errorf("some simple err"),
 			ExpectedErr:    ErrDBRequestFailed,
 		},
+		{
+			SearchPhrase:   "interting",
+			ExpectedPhrase: "interting",
+			ExpectedVideos: nil,
+			MockErr:        fmt.Errorf("%w: context canceled", storage.ErrQueryCanceled),
+			ExpectedErr:    ErrTimeout,
+		},
 	}
 
 	for i, tc := range cases {
@@ -65,7 +73,7 @@ func TestGetVideosByCaption(t *testing.T) {
 				expectedError:  tc.MockErr,
 				videosToReturn: tc.ExpectedVideos,
 			}
-			actualFoundVideos, actualErr := GetVideosByCaption(mock, tc.SearchPhrase)
+			actualResult, actualErr := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: tc.SearchPhrase}, DefaultPageLimits, nil)
 			if t.Failed() {
 				return
 			}
@@ -73,6 +81,10 @@ func TestGetVideosByCaption(t *testing.T) {
 				t.Error(err)
 				return
 			}
+			var actualFoundVideos []*storage.FoundVideo
+			if actualResult != nil {
+				actualFoundVideos = actualResult.Videos
+			}
 			if len(actualFoundVideos) != len(tc.ExpectedVideos) {
 				t.Errorf("expected viceos len %d, got %d", len(tc.ExpectedVideos), len(actualFoundVideos))
 				return
@@ -88,6 +100,392 @@ func TestGetVideosByCaption(t *testing.T) {
 	}
 }
 
+func TestGetVideosByCaptionPagination(t *testing.T) {
+	limits := PageLimits{Default: 2, Max: 3}
+	cursorTime := time.Date(2021, 10, 7, 21, 39, 53, 123456000, time.UTC)
+	page := []*storage.FoundVideo{
+		{ID: 3, Caption: "stuff 3", CreatedAt: cursorTime.Add(time.Hour)},
+		{ID: 2, Caption: "stuff 2", CreatedAt: cursorTime},
+		{ID: 1, Caption: "stuff 1", CreatedAt: cursorTime.Add(-time.Hour)},
+	}
+	cases := []struct {
+		Request            SearchRequest
+		VideosToReturn     []*storage.FoundVideo
+		ExpectedLimit      int
+		ExpectedOffset     int
+		ExpectedAfter      *storage.Cursor
+		ExpectedVideos     int
+		ExpectedNextCursor bool
+		ExpectedErr        error
+	}{
+		{
+			Request:            SearchRequest{Phrase: "stuff"},
+			VideosToReturn:     page,
+			ExpectedLimit:      3,
+			ExpectedVideos:     2,
+			ExpectedNextCursor: true,
+		},
+		{
+			Request:        SearchRequest{Phrase: "stuff", Limit: 3, Offset: 1},
+			VideosToReturn: page,
+			ExpectedLimit:  4,
+			ExpectedOffset: 1,
+			ExpectedVideos: 3,
+		},
+		{
+			Request: SearchRequest{
+				Phrase: "stuff",
+				Cursor: encodeCursor(&pageCursor{
+					Sort:  storage.SortCreatedAt,
+					After: &storage.Cursor{CreatedAt: cursorTime, ID: 2},
+				}),
+			},
+			VideosToReturn: page[2:],
+			ExpectedLimit:  3,
+			ExpectedAfter:  &storage.Cursor{CreatedAt: cursorTime, ID: 2},
+			ExpectedVideos: 1,
+		},
+		{
+			Request:     SearchRequest{Phrase: "stuff", Limit: 4},
+			ExpectedErr: ErrIncorrectPage,
+		},
+		{
+			Request:     SearchRequest{Phrase: "stuff", Offset: -1},
+			ExpectedErr: ErrIncorrectPage,
+		},
+		{
+			Request:     SearchRequest{Phrase: "stuff", Cursor: "not a cursor"},
+			ExpectedErr: ErrIncorrectPage,
+		},
+		{
+			Request: SearchRequest{
+				Phrase: "stuff",
+				Offset: 1,
+				Cursor: encodeCursor(&pageCursor{
+					Sort:  storage.SortCreatedAt,
+					After: &storage.Cursor{CreatedAt: cursorTime, ID: 2},
+				}),
+			},
+			ExpectedErr: ErrIncorrectPage,
+		},
+		{
+			Request:            SearchRequest{Phrase: "stuff", Sort: "likes", Offset: 4},
+			VideosToReturn:     page,
+			ExpectedLimit:      3,
+			ExpectedOffset:     4,
+			ExpectedVideos:     2,
+			ExpectedNextCursor: true,
+		},
+		{
+			Request: SearchRequest{
+				Phrase: "stuff",
+				Sort:   "likes",
+				Cursor: encodeCursor(&pageCursor{Sort: storage.SortLikes, Offset: 6}),
+			},
+			VideosToReturn: page[:1],
+			ExpectedLimit:  3,
+			ExpectedOffset: 6,
+			ExpectedVideos: 1,
+		},
+		{
+			Request: SearchRequest{
+				Phrase: "stuff",
+				Sort:   "likes",
+				Order:  "asc",
+				Cursor: encodeCursor(&pageCursor{Sort: storage.SortLikes, Offset: 6}),
+			},
+			ExpectedErr: ErrIncorrectPage,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &pageDBMock{
+				videosToReturn: tc.VideosToReturn,
+			}
+			res, err := GetVideosByCaption(context.Background(), mock, &tc.Request, limits, nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			q := mock.query
+			if q.Limit != tc.ExpectedLimit || q.Offset != tc.ExpectedOffset {
+				t.Errorf("expected limit %d and offset %d, got %d and %d", tc.ExpectedLimit, tc.ExpectedOffset, q.Limit, q.Offset)
+			}
+			if (tc.ExpectedAfter == nil) != (q.After == nil) ||
+				(q.After != nil && (!q.After.CreatedAt.Equal(tc.ExpectedAfter.CreatedAt) || q.After.ID != tc.ExpectedAfter.ID)) {
+				t.Errorf("expected cursor %v, got %v", tc.ExpectedAfter, q.After)
+			}
+			if len(res.Videos) != tc.ExpectedVideos {
+				t.Errorf("expected %d videos, got %d", tc.ExpectedVideos, len(res.Videos))
+			}
+			if tc.ExpectedNextCursor != (res.NextCursor != "") {
+				t.Errorf("expected next cursor: %v, got %q", tc.ExpectedNextCursor, res.NextCursor)
+			}
+		})
+	}
+}
+
+func TestGetVideosByCaptionSort(t *testing.T) {
+	cases := []struct {
+		Sort         string
+		Order        string
+		ExpectedSort storage.SortField
+		ExpectedAsc  bool
+		ExpectedErr  error
+	}{
+		{
+			ExpectedSort: storage.SortCreatedAt,
+		},
+		{
+			Sort:         "updated_at",
+			Order:        "asc",
+			ExpectedSort: storage.SortUpdatedAt,
+			ExpectedAsc:  true,
+		},
+		{
+			Sort:         "relevance",
+			Order:        "desc",
+			ExpectedSort: storage.SortRelevance,
+		},
+		{
+			Sort:         "likes",
+			ExpectedSort: storage.SortLikes,
+		},
+		{
+			Sort:        "caption",
+			ExpectedErr: ErrIncorrectSort,
+		},
+		{
+			Sort:        "likes",
+			Order:       "up",
+			ExpectedErr: ErrIncorrectSort,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &pageDBMock{}
+			_, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "stuff", Sort: tc.Sort, Order: tc.Order}, DefaultPageLimits, nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			if mock.query.Sort != tc.ExpectedSort || mock.query.Asc != tc.ExpectedAsc {
+				t.Errorf("expected sort %s (asc: %v), got %s (asc: %v)", tc.ExpectedSort, tc.ExpectedAsc, mock.query.Sort, mock.query.Asc)
+			}
+		})
+	}
+}
+
+func TestGetVideosByCaptionFullText(t *testing.T) {
+	mock := &pageDBMock{
+		videosToReturn: []*storage.FoundVideo{
+			{ID: 2, Caption: "Interesting stuff", Rank: 0.6, Snippet: "<b>Interesting</b> stuff"},
+			{ID: 1, Caption: "Stuff", Rank: 0.3, Snippet: "<b>interesting</b> description"},
+		},
+	}
+	res, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Interesting", Mode: ModeFullText, Limit: 1}, DefaultPageLimits, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.query != nil {
+		t.Fatalf("expected the full-text search, got a caption query")
+	}
+	if mock.textQuery.Phrase != "Interesting" || mock.textQuery.Limit != 2 || mock.textQuery.Asc {
+		t.Errorf("unexpected text query: %v", *mock.textQuery)
+	}
+	if len(res.Videos) != 1 || res.NextCursor == "" {
+		t.Fatalf("expected one video and a next cursor, got %d videos and cursor %q", len(res.Videos), res.NextCursor)
+	}
+
+	res, err = GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Interesting", Mode: ModeFullText, Cursor: res.NextCursor}, DefaultPageLimits, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.textQuery.Offset != 1 {
+		t.Errorf("expected offset 1 from the cursor, got %d", mock.textQuery.Offset)
+	}
+
+	_, err = GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Interesting", Mode: ModeFullText, Sort: "likes"}, DefaultPageLimits, nil)
+	if err := compareErrs(ErrIncorrectSort, err); err != nil {
+		t.Error(err)
+	}
+	_, err = GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Interesting", Mode: "regexp"}, DefaultPageLimits, nil)
+	if err := compareErrs(ErrIncorrectMode, err); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetVideosByCaptionFuzzy(t *testing.T) {
+	cases := []struct {
+		Request     SearchRequest
+		ExpectedErr error
+	}{
+		{
+			Request: SearchRequest{Phrase: "Intresting", Fuzzy: true, Threshold: 0.4},
+		},
+		{
+			Request: SearchRequest{Phrase: "Intresting", Fuzzy: true, Threshold: 0.4, Sort: "relevance", Order: "asc"},
+		},
+		{
+			Request:     SearchRequest{Phrase: "Intresting", Fuzzy: true},
+			ExpectedErr: ErrIncorrectThreshold,
+		},
+		{
+			Request:     SearchRequest{Phrase: "Intresting", Fuzzy: true, Threshold: 1.5},
+			ExpectedErr: ErrIncorrectThreshold,
+		},
+		{
+			Request:     SearchRequest{Phrase: "Intresting", Fuzzy: true, Threshold: 0.4, Sort: "created_at"},
+			ExpectedErr: ErrIncorrectSort,
+		},
+		{
+			Request:     SearchRequest{Phrase: "Intresting", Fuzzy: true, Threshold: 0.4, Mode: ModeFullText},
+			ExpectedErr: ErrIncorrectMode,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("test case #%d", i), func(t *testing.T) {
+			mock := &pageDBMock{}
+			_, err := GetVideosByCaption(context.Background(), mock, &tc.Request, DefaultPageLimits, nil)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+				return
+			}
+			if tc.ExpectedErr != nil {
+				return
+			}
+			q := mock.similarity
+			if q == nil {
+				t.Fatalf("expected the fuzzy search to be used")
+			}
+			if q.Phrase != "intresting" || q.Threshold != tc.Request.Threshold || q.Asc != (tc.Request.Order == OrderAsc) {
+				t.Errorf("unexpected similarity query: %v", *q)
+			}
+		})
+	}
+}
+
+func TestGetVideosByCaptionPattern(t *testing.T) {
+	mock := &pageDBMock{}
+	if _, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "100%"}, DefaultPageLimits, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.query.Pattern || mock.query.Phrase != "100%" {
+		t.Errorf("expected a literal phrase, got %v", *mock.query)
+	}
+	if _, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Top_%", Mode: ModePattern}, DefaultPageLimits, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mock.query.Pattern || mock.query.Phrase != "top_%" {
+		t.Errorf("expected a pattern, got %v", *mock.query)
+	}
+	_, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Top_%", Mode: ModePattern, Sort: "relevance"}, DefaultPageLimits, nil)
+	if err := compareErrs(ErrIncorrectSort, err); err != nil {
+		t.Error(err)
+	}
+	_, err = GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "Top_%", Mode: ModePattern, Fuzzy: true, Threshold: 0.3}, DefaultPageLimits, nil)
+	if err := compareErrs(ErrIncorrectMode, err); err != nil {
+		t.Error(err)
+	}
+	_, err = GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: `50\%\`, Mode: ModePattern}, DefaultPageLimits, nil)
+	if err := compareErrs(ErrIncorrectCaptionSubstring, err); err != nil {
+		t.Error(err)
+	}
+	if _, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: `c:\\`, Mode: ModePattern}, DefaultPageLimits, nil); err != nil {
+		t.Errorf("expected an escaped backslash to be accepted, got: %v", err)
+	}
+}
+
+func TestGetVideosByCaptionContext(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	mock := &pageDBMock{}
+	if _, err := GetVideosByCaption(ctx, mock, &SearchRequest{Phrase: "stuff"}, DefaultPageLimits, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.ctx == nil || mock.ctx.Value(ctxKey{}) != "request" {
+		t.Errorf("expected the caller context to reach the DB")
+	}
+}
+
+func TestGetVideosByCaptionObserver(t *testing.T) {
+	videos := make([]*storage.FoundVideo, 3)
+	for i := range videos {
+		videos[i] = &storage.FoundVideo{ID: i + 1}
+	}
+	cases := []struct {
+		Request      SearchRequest
+		ExpectedMode string
+	}{
+		{Request: SearchRequest{Phrase: "stuff"}, ExpectedMode: ModeSubstring},
+		{Request: SearchRequest{Phrase: "stuff%", Mode: ModePattern}, ExpectedMode: ModePattern},
+		{Request: SearchRequest{Phrase: "stuff", Mode: ModeFullText}, ExpectedMode: ModeFullText},
+		{Request: SearchRequest{Phrase: "stuff", Fuzzy: true, Threshold: 0.3}, ExpectedMode: ModeFuzzy},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ExpectedMode, func(t *testing.T) {
+			obs := &searchObserverMock{}
+			mock := &pageDBMock{videosToReturn: videos}
+			// the extra row telling there is a next page is not counted
+			if _, err := GetVideosByCaption(context.Background(), mock, &tc.Request, PageLimits{Default: 2, Max: 10}, obs); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if obs.mode != tc.ExpectedMode || obs.results != 2 {
+				t.Errorf("expected 2 results of the %s search, got %d of %q", tc.ExpectedMode, obs.results, obs.mode)
+			}
+		})
+	}
+}
+
+type searchObserverMock struct {
+	mode    string
+	results int
+}
+
+func (o *searchObserverMock) ObserveSearch(mode string, results int) {
+	o.mode, o.results = mode, results
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cases := []*pageCursor{
+		{
+			Sort: storage.SortCreatedAt,
+			After: &storage.Cursor{
+				CreatedAt: time.Date(1973, 4, 1, 23, 6, 0, 1000, time.UTC),
+				ID:        42,
+			},
+		},
+		{
+			Sort:   storage.SortRelevance,
+			Asc:    true,
+			Offset: 40,
+		},
+	}
+	for i, expected := range cases {
+		actual, err := decodeCursor(encodeCursor(expected))
+		if err != nil {
+			t.Fatalf("cursor %d: failed to decode: %v", i, err)
+		}
+		if actual.Sort != expected.Sort || actual.Asc != expected.Asc || actual.Offset != expected.Offset {
+			t.Errorf("cursor %d: expected %v, got %v", i, *expected, *actual)
+		}
+		if expected.After != nil && (actual.After == nil ||
+			!actual.After.CreatedAt.Equal(expected.After.CreatedAt) || actual.After.ID != expected.After.ID) {
+			t.Errorf("cursor %d: expected position %v, got %v", i, *expected.After, actual.After)
+		}
+	}
+}
+
 func compareErrs(expectedErr error, actualErr error) error {
 	if expectedErr == nil && actualErr == nil {
 		return nil
@@ -102,18 +500,86 @@ func compareErrs(expectedErr error, actualErr error) error {
 }
 
 type dbMock struct {
+	// the methods the search does not call are left unimplemented
+	storage.DB
+
 	t              *testing.T
 	expectedPrefix string
 	expectedError  error
 	videosToReturn []*storage.FoundVideo
 }
 
-func (db *dbMock) GetVideosByCaption(ctx context.Context, captionSubstring string) ([]*storage.FoundVideo, error) {
-	if captionSubstring != db.expectedPrefix {
-		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedPrefix, captionSubstring)
+func (db *dbMock) GetVideosByCaption(ctx context.Context, q *storage.CaptionQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedPrefix {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedPrefix, q.Phrase)
+		return nil, nil
+	}
+	return db.videosToReturn, db.expectedError
+}
+
+func (db *dbMock) SearchVideos(ctx context.Context, q *storage.TextQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedPrefix {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedPrefix, q.Phrase)
+		return nil, nil
+	}
+	return db.videosToReturn, db.expectedError
+}
+
+func (db *dbMock) GetVideosByCaptionSimilarity(ctx context.Context, q *storage.SimilarityQuery) ([]*storage.FoundVideo, error) {
+	if q.Phrase != db.expectedPrefix {
+		db.t.Errorf("error in DB mock: expected search phrase: %s, got: %s", db.expectedPrefix, q.Phrase)
 		return nil, nil
 	}
 	return db.videosToReturn, db.expectedError
 }
 
 func (db *dbMock) Close() {}
+
+func (db *dbMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
+
+// pageDBMock records the query it gets and returns at most q.Limit videos
+type pageDBMock struct {
+	storage.DB
+
+	ctx            context.Context
+	query          *storage.CaptionQuery
+	textQuery      *storage.TextQuery
+	similarity     *storage.SimilarityQuery
+	videosToReturn []*storage.FoundVideo
+}
+
+func (db *pageDBMock) GetVideosByCaption(ctx context.Context, q *storage.CaptionQuery) ([]*storage.FoundVideo, error) {
+	db.ctx = ctx
+	db.query = q
+	videos := db.videosToReturn
+	if len(videos) > q.Limit {
+		videos = videos[:q.Limit]
+	}
+	return videos, nil
+}
+
+func (db *pageDBMock) SearchVideos(ctx context.Context, q *storage.TextQuery) ([]*storage.FoundVideo, error) {
+	db.textQuery = q
+	videos := db.videosToReturn
+	if len(videos) > q.Limit {
+		videos = videos[:q.Limit]
+	}
+	return videos, nil
+}
+
+func (db *pageDBMock) GetVideosByCaptionSimilarity(ctx context.Context, q *storage.SimilarityQuery) ([]*storage.FoundVideo, error) {
+	db.similarity = q
+	videos := db.videosToReturn
+	if len(videos) > q.Limit {
+		videos = videos[:q.Limit]
+	}
+	return videos, nil
+}
+
+func (db *pageDBMock) Close() {}
+
+func (db *pageDBMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+var ErrIncorrectUser = newError(KindValidation, "incorrect_user", "got an incorrect user")
+
+// limits of the users table columns
+const (
+	maxNameLen  = 50
+	maxEmailLen = 254
+	maxLoginLen = 50
+)
+
+var loginRe = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// FieldError tells what is wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors lists every incorrect field of a request, errors.Is matches it with its Kind
+type FieldErrors struct {
+	Kind   error
+	Fields []FieldError
+}
+
+func (e *FieldErrors) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return fmt.Sprintf("%v: %s", e.Kind, strings.Join(msgs, ", "))
+}
+
+func (e *FieldErrors) Unwrap() error {
+	return e.Kind
+}
+
+func (e *FieldErrors) add(field string, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// UserPage is a page of users, NextCursor is empty on the last page
+type UserPage struct {
+	Users      []*storage.User `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// CreateUser registers a user, emails are stored in lower case
+func CreateUser(ctx context.Context, db storage.DB, u *storage.User) (*storage.User, error) {
+	u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+	err := validateUser(&storage.UserUpdate{
+		Name:     &u.Name,
+		Email:    &u.Email,
+		Login:    &u.Login,
+		Birthday: &u.Birthday,
+		About:    u.About,
+	})
+	if err != nil {
+		return nil, err
+	}
+	created, err := db.CreateUser(ctx, u)
+	if err != nil {
+		return nil, mapUserWriteError(err)
+	}
+	return created, nil
+}
+
+func GetUser(ctx context.Context, db storage.DB, id int) (*storage.User, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: user %d", ErrNotFound, id)
+	}
+	u, err := db.GetUser(ctx, id)
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	return u, nil
+}
+
+func UpdateUser(ctx context.Context, db storage.DB, id int, upd *storage.UserUpdate) (*storage.User, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: user %d", ErrNotFound, id)
+	}
+	if upd.Name == nil && upd.Email == nil && upd.Login == nil && upd.Birthday == nil && upd.About == nil && !upd.ClearAbout {
+		return nil, fmt.Errorf("%w: no fields to update", ErrIncorrectUser)
+	}
+	if upd.ClearAbout && upd.About != nil {
+		return nil, fmt.Errorf("%w: about can not be both set and cleared", ErrIncorrectUser)
+	}
+	if upd.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*upd.Email))
+		upd.Email = &email
+	}
+	if err := validateUser(upd); err != nil {
+		return nil, err
+	}
+	u, err := db.UpdateUser(ctx, id, upd)
+	if err != nil {
+		return nil, mapUserWriteError(err)
+	}
+	return u, nil
+}
+
+// ListUsers returns a page of users ordered by registration
+func ListUsers(ctx context.Context, db storage.DB, limit int, cursor string, limits PageLimits) (*UserPage, error) {
+	if limit == 0 {
+		limit = limits.Default
+	}
+	if limit < 0 || limit > limits.Max {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d, got %d", ErrIncorrectPage, limits.Max, limit)
+	}
+	q := &storage.UserQuery{
+		// one extra row tells whether there is a next page
+		Limit: limit + 1,
+	}
+	if cursor != "" {
+		var err error
+		if q.AfterID, err = decodeIDCursor(cursor); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectPage, err)
+		}
+	}
+	users, err := db.ListUsers(ctx, q)
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	page := &UserPage{
+		Users: users,
+	}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = encodeIDCursor(page.Users[limit-1].ID)
+	}
+	return page, nil
+}
+
+// validateUser checks the set fields against the constraints of the users table
+func validateUser(upd *storage.UserUpdate) error {
+	errs := &FieldErrors{Kind: ErrIncorrectUser}
+	if upd.Name != nil {
+		validateUserField(errs, "name", *upd.Name, maxNameLen)
+	}
+	if upd.Email != nil {
+		validateUserField(errs, "email", *upd.Email, maxEmailLen)
+		if addr, err := mail.ParseAddress(*upd.Email); err != nil || addr.Address != *upd.Email {
+			errs.add("email", "must be a plain email address")
+		}
+	}
+	if upd.Login != nil {
+		validateUserField(errs, "login", *upd.Login, maxLoginLen)
+		if !loginRe.MatchString(*upd.Login) {
+			errs.add("login", "must only contain latin letters, digits, dots, dashes and underscores")
+		}
+	}
+	// mirrors users_valid_birthday
+	if upd.Birthday != nil && !upd.Birthday.Before(time.Now()) {
+		errs.add("birthday", "must be in the past")
+	}
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateUserField(errs *FieldErrors, field string, val string, maxLen int) {
+	if strings.TrimSpace(val) == "" {
+		errs.add(field, "must not be empty")
+		return
+	}
+	if utf8.RuneCountInString(val) > maxLen {
+		errs.add(field, "must be at most %d characters long", maxLen)
+	}
+}
+
+func mapUserWriteError(err error) error {
+	var cErr *storage.ConstraintError
+	if !errors.As(err, &cErr) {
+		return mapDBError(err)
+	}
+	errs := &FieldErrors{Kind: ErrIncorrectUser}
+	if errors.Is(err, storage.ErrConflict) {
+		errs.Kind = ErrConflict
+	}
+	if cErr.Field == "" {
+		return fmt.Errorf("%w: %v", errs.Kind, err)
+	}
+	if errs.Kind == ErrConflict {
+		errs.add(cErr.Field, "is already taken")
+	} else {
+		errs.add(cErr.Field, "violates the %s constraint", cErr.Constraint)
+	}
+	return errs
+}
+
+func encodeIDCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeIDCursor(s string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, fmt.Errorf("malformed cursor: %w", err)
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("malformed cursor %q", s)
+	}
+	return id, nil
+}
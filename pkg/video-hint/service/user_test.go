@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestCreateUser(t *testing.T) {
+	valid := func() *storage.User {
+		return &storage.User{
+			Name:     "Ivan",
+			Email:    "Ivan@Example.com",
+			Login:    "ivan_1",
+			Birthday: time.Date(1990, time.May, 1, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	cases := []struct {
+		Name           string
+		Modify         func(u *storage.User)
+		MockErr        error
+		ExpectedErr    error
+		ExpectedFields []string
+	}{
+		{
+			Name:   "valid user",
+			Modify: func(u *storage.User) {},
+		},
+		{
+			Name:           "empty name",
+			Modify:         func(u *storage.User) { u.Name = "" },
+			ExpectedErr:    ErrIncorrectUser,
+			ExpectedFields: []string{"name"},
+		},
+		{
+			Name: "several incorrect fields",
+			Modify: func(u *storage.User) {
+				u.Email = "ivan"
+				u.Login = strings.Repeat("i", maxLoginLen+1)
+			},
+			ExpectedErr:    ErrIncorrectUser,
+			ExpectedFields: []string{"email", "login"},
+		},
+		{
+			Name:           "login with spaces",
+			Modify:         func(u *storage.User) { u.Login = "ivan petrov" },
+			ExpectedErr:    ErrIncorrectUser,
+			ExpectedFields: []string{"login"},
+		},
+		{
+			Name:           "birthday in the future",
+			Modify:         func(u *storage.User) { u.Birthday = time.Now().Add(24 * time.Hour) },
+			ExpectedErr:    ErrIncorrectUser,
+			ExpectedFields: []string{"birthday"},
+		},
+		{
+			Name:           "taken email",
+			Modify:         func(u *storage.User) {},
+			MockErr:        &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "users_email_key", Field: "email"},
+			ExpectedErr:    ErrConflict,
+			ExpectedFields: []string{"email"},
+		},
+		{
+			Name:           "taken login",
+			Modify:         func(u *storage.User) {},
+			MockErr:        &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "users_login_key", Field: "login"},
+			ExpectedErr:    ErrConflict,
+			ExpectedFields: []string{"login"},
+		},
+		{
+			Name:        "timeout",
+			Modify:      func(u *storage.User) {},
+			MockErr:     fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedErr: ErrTimeout,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			u := valid()
+			tc.Modify(u)
+			mock := &userDBMock{err: tc.MockErr}
+			_, err := CreateUser(context.Background(), mock, u)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Fatal(err)
+			}
+			if err == nil && mock.created.Email != "ivan@example.com" {
+				t.Errorf("expected the email to be stored in lower case, got %q", mock.created.Email)
+			}
+			if err := compareFields(tc.ExpectedFields, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	login := "petr"
+	future := time.Now().Add(24 * time.Hour)
+	cases := []struct {
+		Name        string
+		Update      *storage.UserUpdate
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Name:   "login",
+			Update: &storage.UserUpdate{Login: &login},
+		},
+		{
+			Name:   "clear about",
+			Update: &storage.UserUpdate{ClearAbout: true},
+		},
+		{
+			Name:        "set and clear about",
+			Update:      &storage.UserUpdate{About: &login, ClearAbout: true},
+			ExpectedErr: ErrIncorrectUser,
+		},
+		{
+			Name:        "no fields",
+			Update:      &storage.UserUpdate{},
+			ExpectedErr: ErrIncorrectUser,
+		},
+		{
+			Name:        "birthday in the future",
+			Update:      &storage.UserUpdate{Birthday: &future},
+			ExpectedErr: ErrIncorrectUser,
+		},
+		{
+			Name:        "missing user",
+			Update:      &storage.UserUpdate{Login: &login},
+			MockErr:     fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := UpdateUser(context.Background(), &userDBMock{err: tc.MockErr}, 1, tc.Update)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	users := make([]*storage.User, 5)
+	for i := range users {
+		users[i] = &storage.User{ID: i + 1}
+	}
+	mock := &userDBMock{users: users}
+
+	page, err := ListUsers(context.Background(), mock, 2, "", DefaultPageLimits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Users) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected 2 users and a next cursor, got %d users and cursor %q", len(page.Users), page.NextCursor)
+	}
+	page, err = ListUsers(context.Background(), mock, 10, page.NextCursor, DefaultPageLimits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.query.AfterID != 2 {
+		t.Errorf("expected the page to start after user 2, got %d", mock.query.AfterID)
+	}
+	if len(page.Users) != 3 || page.NextCursor != "" {
+		t.Errorf("expected the last 3 users without a next cursor, got %d users and cursor %q", len(page.Users), page.NextCursor)
+	}
+
+	if _, err := ListUsers(context.Background(), mock, DefaultPageLimits.Max+1, "", DefaultPageLimits); !errors.Is(err, ErrIncorrectPage) {
+		t.Errorf("expected %v for a too large limit, got %v", ErrIncorrectPage, err)
+	}
+	if _, err := ListUsers(context.Background(), mock, 0, "not a cursor", DefaultPageLimits); !errors.Is(err, ErrIncorrectPage) {
+		t.Errorf("expected %v for a malformed cursor, got %v", ErrIncorrectPage, err)
+	}
+}
+
+// compareFields checks that err lists exactly the expected incorrect fields
+func compareFields(expected []string, err error) error {
+	var fieldErrs *FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		if len(expected) > 0 {
+			return fmt.Errorf("expected errors of fields %v, got %v", expected, err)
+		}
+		return nil
+	}
+	actual := make([]string, 0, len(fieldErrs.Fields))
+	for _, f := range fieldErrs.Fields {
+		actual = append(actual, f.Field)
+	}
+	if strings.Join(actual, ",") != strings.Join(expected, ",") {
+		return fmt.Errorf("expected errors of fields %v, got %v", expected, actual)
+	}
+	return nil
+}
+
+// userDBMock fails every user call with err, ListUsers pages through users
+type userDBMock struct {
+	storage.DB
+
+	err     error
+	created *storage.User
+	users   []*storage.User
+	query   *storage.UserQuery
+}
+
+func (db *userDBMock) CreateUser(ctx context.Context, u *storage.User) (*storage.User, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	db.created = u
+	created := *u
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *userDBMock) UpdateUser(ctx context.Context, id int, upd *storage.UserUpdate) (*storage.User, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.User{ID: id}, nil
+}
+
+func (db *userDBMock) ListUsers(ctx context.Context, q *storage.UserQuery) ([]*storage.User, error) {
+	db.query = q
+	users := make([]*storage.User, 0, q.Limit)
+	for _, u := range db.users {
+		if u.ID > q.AfterID && len(users) < q.Limit {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+var (
+	ErrIncorrectVideo = newError(KindValidation, "incorrect_video", "got an incorrect video")
+	ErrNotFound       = newError(KindNotFound, "not_found", "the requested object is not found")
+	ErrConflict       = newError(KindConflict, "conflict", "the object conflicts with an existing one")
+)
+
+// resolutions lists the values of the resolution type
+var resolutions = []string{"144p", "240p", "360p", "480p", "720p", "1080p"}
+
+// limits of the videos table columns
+const (
+	maxLocationLen = 255
+	maxURILen      = 255
+	maxCaptionLen  = 255
+)
+
+func CreateVideo(ctx context.Context, db storage.DB, v *storage.Video) (*storage.Video, error) {
+	if v.UserID <= 0 {
+		return nil, fmt.Errorf("%w: user_id must be positive, got %d", ErrIncorrectVideo, v.UserID)
+	}
+	err := validateVideo(&storage.VideoUpdate{
+		Location:    &v.Location,
+		URI:         &v.URI,
+		RES:         &v.RES,
+		Caption:     &v.Caption,
+		Description: &v.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	created, err := db.CreateVideo(ctx, v)
+	if err != nil {
+		return nil, mapVideoWriteError(err)
+	}
+	return created, nil
+}
+
+func GetVideo(ctx context.Context, db storage.DB, id int) (*storage.Video, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: video %d", ErrNotFound, id)
+	}
+	v, err := db.GetVideo(ctx, id)
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	if err := fillVideoVotes(ctx, db, v); err != nil {
+		return nil, mapDBError(err)
+	}
+	return v, nil
+}
+
+func UpdateVideo(ctx context.Context, db storage.DB, id int, upd *storage.VideoUpdate) (*storage.Video, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("%w: video %d", ErrNotFound, id)
+	}
+	if upd.Location == nil && upd.URI == nil && upd.RES == nil && upd.Caption == nil && upd.Description == nil {
+		return nil, fmt.Errorf("%w: no fields to update", ErrIncorrectVideo)
+	}
+	if err := validateVideo(upd); err != nil {
+		return nil, err
+	}
+	v, err := db.UpdateVideo(ctx, id, upd)
+	if err != nil {
+		return nil, mapVideoWriteError(err)
+	}
+	if err := fillVideoVotes(ctx, db, v); err != nil {
+		return nil, mapDBError(err)
+	}
+	return v, nil
+}
+
+// DeleteVideo removes a video, videos having comments or likes can not be deleted
+func DeleteVideo(ctx context.Context, db storage.DB, id int) error {
+	if id <= 0 {
+		return fmt.Errorf("%w: video %d", ErrNotFound, id)
+	}
+	if err := db.DeleteVideo(ctx, id); err != nil {
+		// comments and likes restrict the deletion of their video
+		if errors.Is(err, storage.ErrConstraint) {
+			return fmt.Errorf("%w: video %d is referenced: %v", ErrConflict, id, err)
+		}
+		return mapDBError(err)
+	}
+	return nil
+}
+
+// validateVideo checks the set fields against the constraints of the videos table
+func validateVideo(upd *storage.VideoUpdate) error {
+	if upd.Location != nil {
+		if err := validateRequired("location", *upd.Location, maxLocationLen); err != nil {
+			return err
+		}
+	}
+	if upd.URI != nil {
+		if err := validateRequired("uri", *upd.URI, maxURILen); err != nil {
+			return err
+		}
+		if u, err := url.ParseRequestURI(*upd.URI); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: uri must be an absolute URL, got %q", ErrIncorrectVideo, *upd.URI)
+		}
+	}
+	if upd.RES != nil && !isResolution(*upd.RES) {
+		return fmt.Errorf("%w: res must be one of %s, got %q", ErrIncorrectVideo, strings.Join(resolutions, ", "), *upd.RES)
+	}
+	if upd.Caption != nil {
+		if err := validateRequired("caption", *upd.Caption, maxCaptionLen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRequired(field string, val string, maxLen int) error {
+	if strings.TrimSpace(val) == "" {
+		return fmt.Errorf("%w: %s must not be empty", ErrIncorrectVideo, field)
+	}
+	if utf8.RuneCountInString(val) > maxLen {
+		return fmt.Errorf("%w: %s must be at most %d characters long", ErrIncorrectVideo, field, maxLen)
+	}
+	return nil
+}
+
+func isResolution(res string) bool {
+	for _, r := range resolutions {
+		if r == res {
+			return true
+		}
+	}
+	return false
+}
+
+func mapVideoWriteError(err error) error {
+	if errors.Is(err, storage.ErrConstraint) {
+		return fmt.Errorf("%w: %v", ErrIncorrectVideo, err)
+	}
+	return mapDBError(err)
+}
+
+// mapDBError translates the storage errors into the service ones
+func mapDBError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, storage.ErrConflict):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case errors.Is(err, storage.ErrQueryCanceled):
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrDBRequestFailed, err)
+	}
+}
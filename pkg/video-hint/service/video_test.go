@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestCreateVideo(t *testing.T) {
+	valid := func() *storage.Video {
+		return &storage.Video{
+			UserID:   1,
+			Location: "/srv/videos/1.mp4",
+			URI:      "https://videos.example.com/1",
+			RES:      "720p",
+			Caption:  "repudiandae",
+		}
+	}
+	cases := []struct {
+		Name        string
+		Modify      func(v *storage.Video)
+		MockErr     error
+		ExpectedErr error
+		CallsDB     bool
+	}{
+		{
+			Name:    "valid video",
+			Modify:  func(v *storage.Video) {},
+			CallsDB: true,
+		},
+		{
+			Name:        "zero user",
+			Modify:      func(v *storage.Video) { v.UserID = 0 },
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "empty location",
+			Modify:      func(v *storage.Video) { v.Location = " " },
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "relative uri",
+			Modify:      func(v *storage.Video) { v.URI = "videos/1" },
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "unknown resolution",
+			Modify:      func(v *storage.Video) { v.RES = "4k" },
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "too long caption",
+			Modify:      func(v *storage.Video) { v.Caption = strings.Repeat("я", maxCaptionLen+1) },
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "duplicate uri",
+			Modify:      func(v *storage.Video) {},
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConflict, Constraint: "videos_uri_key", Field: "uri"},
+			ExpectedErr: ErrConflict,
+			CallsDB:     true,
+		},
+		{
+			Name:        "unknown user",
+			Modify:      func(v *storage.Video) {},
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "videos_fk_user_id", Field: "user_id"},
+			ExpectedErr: ErrIncorrectVideo,
+			CallsDB:     true,
+		},
+		{
+			Name:        "timeout",
+			Modify:      func(v *storage.Video) {},
+			MockErr:     fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedErr: ErrTimeout,
+			CallsDB:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			v := valid()
+			tc.Modify(v)
+			mock := &videoDBMock{err: tc.MockErr}
+			_, err := CreateVideo(context.Background(), mock, v)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+			if mock.called != tc.CallsDB {
+				t.Errorf("expected DB to be called: %v, got: %v", tc.CallsDB, mock.called)
+			}
+		})
+	}
+}
+
+func TestUpdateVideo(t *testing.T) {
+	caption := "repudiandae"
+	empty := ""
+	cases := []struct {
+		Name        string
+		ID          int
+		Update      *storage.VideoUpdate
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Name:   "caption",
+			ID:     1,
+			Update: &storage.VideoUpdate{Caption: &caption},
+		},
+		{
+			Name:        "no fields",
+			ID:          1,
+			Update:      &storage.VideoUpdate{},
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "empty caption",
+			ID:          1,
+			Update:      &storage.VideoUpdate{Caption: &empty},
+			ExpectedErr: ErrIncorrectVideo,
+		},
+		{
+			Name:        "description may be empty",
+			ID:          1,
+			Update:      &storage.VideoUpdate{Description: &empty},
+			ExpectedErr: nil,
+		},
+		{
+			Name:        "negative id",
+			ID:          -1,
+			Update:      &storage.VideoUpdate{Caption: &caption},
+			ExpectedErr: ErrNotFound,
+		},
+		{
+			Name:        "missing video",
+			ID:          1,
+			Update:      &storage.VideoUpdate{Caption: &caption},
+			MockErr:     fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedErr: ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := UpdateVideo(context.Background(), &videoDBMock{err: tc.MockErr}, tc.ID, tc.Update)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDeleteVideo(t *testing.T) {
+	cases := []struct {
+		Name        string
+		MockErr     error
+		ExpectedErr error
+	}{
+		{
+			Name: "deleted",
+		},
+		{
+			Name:        "missing video",
+			MockErr:     fmt.Errorf("%w: no rows", storage.ErrNotFound),
+			ExpectedErr: ErrNotFound,
+		},
+		{
+			Name:        "video has comments",
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "comments_fk_video_id"},
+			ExpectedErr: ErrConflict,
+		},
+		{
+			Name:        "db failure",
+			MockErr:     fmt.Errorf("connection reset"),
+			ExpectedErr: ErrDBRequestFailed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := DeleteVideo(context.Background(), &videoDBMock{err: tc.MockErr}, 1)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// videoDBMock fails every video call with err
+type videoDBMock struct {
+	storage.DB
+
+	err    error
+	called bool
+}
+
+func (db *videoDBMock) CreateVideo(ctx context.Context, v *storage.Video) (*storage.Video, error) {
+	db.called = true
+	if db.err != nil {
+		return nil, db.err
+	}
+	created := *v
+	created.ID = 1
+	return &created, nil
+}
+
+func (db *videoDBMock) GetVideo(ctx context.Context, id int) (*storage.Video, error) {
+	db.called = true
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *videoDBMock) UpdateVideo(ctx context.Context, id int, upd *storage.VideoUpdate) (*storage.Video, error) {
+	db.called = true
+	if db.err != nil {
+		return nil, db.err
+	}
+	return &storage.Video{ID: id}, nil
+}
+
+func (db *videoDBMock) DeleteVideo(ctx context.Context, id int) error {
+	db.called = true
+	return db.err
+}
+
+func (db *videoDBMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	return map[int]storage.Votes{}, nil
+}
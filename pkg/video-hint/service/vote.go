@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+var ErrIncorrectVote = newError(KindValidation, "incorrect_vote", "got an incorrect vote")
+
+// VideoVotes is the tally of a video after a vote
+type VideoVotes struct {
+	VideoID int `json:"video_id"`
+	storage.Votes
+}
+
+// Like casts a like of the user for the video, liking twice changes nothing
+func Like(ctx context.Context, db storage.DB, userID int, videoID int) (*VideoVotes, error) {
+	return vote(ctx, db, userID, videoID, true)
+}
+
+// Dislike casts a dislike of the user for the video, it replaces a like
+func Dislike(ctx context.Context, db storage.DB, userID int, videoID int) (*VideoVotes, error) {
+	return vote(ctx, db, userID, videoID, false)
+}
+
+// Unvote withdraws the vote of the user, withdrawing a missing vote changes nothing
+func Unvote(ctx context.Context, db storage.DB, userID int, videoID int) (*VideoVotes, error) {
+	if err := validateVote(userID, videoID); err != nil {
+		return nil, err
+	}
+	if err := db.Unvote(ctx, userID, videoID); err != nil {
+		return nil, mapDBError(err)
+	}
+	return countVideoVotes(ctx, db, videoID)
+}
+
+func vote(ctx context.Context, db storage.DB, userID int, videoID int, thumbUp bool) (*VideoVotes, error) {
+	if err := validateVote(userID, videoID); err != nil {
+		return nil, err
+	}
+	if err := db.Vote(ctx, userID, videoID, thumbUp); err != nil {
+		var cErr *storage.ConstraintError
+		if errors.As(err, &cErr) && cErr.Field == "video_id" {
+			return nil, fmt.Errorf("%w: video %d: %v", ErrNotFound, videoID, err)
+		}
+		if errors.Is(err, storage.ErrConstraint) {
+			return nil, fmt.Errorf("%w: %v", ErrIncorrectVote, err)
+		}
+		return nil, mapDBError(err)
+	}
+	return countVideoVotes(ctx, db, videoID)
+}
+
+func validateVote(userID int, videoID int) error {
+	if userID <= 0 {
+		return fmt.Errorf("%w: user_id must be positive, got %d", ErrIncorrectVote, userID)
+	}
+	if videoID <= 0 {
+		return fmt.Errorf("%w: video %d", ErrNotFound, videoID)
+	}
+	return nil
+}
+
+func countVideoVotes(ctx context.Context, db storage.DB, videoID int) (*VideoVotes, error) {
+	votes, err := db.CountVotes(ctx, []int{videoID})
+	if err != nil {
+		return nil, mapDBError(err)
+	}
+	return &VideoVotes{
+		VideoID: videoID,
+		Votes:   votes[videoID],
+	}, nil
+}
+
+// fillFoundVotes sets the vote counts of a page of found videos with a single query
+func fillFoundVotes(ctx context.Context, db storage.DB, videos []*storage.FoundVideo) error {
+	if len(videos) == 0 {
+		return nil
+	}
+	ids := make([]int, len(videos))
+	for i, v := range videos {
+		ids[i] = v.ID
+	}
+	votes, err := db.CountVotes(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, v := range videos {
+		v.Votes = votes[v.ID]
+	}
+	return nil
+}
+
+func fillVideoVotes(ctx context.Context, db storage.DB, v *storage.Video) error {
+	votes, err := db.CountVotes(ctx, []int{v.ID})
+	if err != nil {
+		return err
+	}
+	v.Votes = votes[v.ID]
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+func TestVote(t *testing.T) {
+	cases := []struct {
+		Name        string
+		Vote        func(ctx context.Context, db storage.DB, userID int, videoID int) (*VideoVotes, error)
+		UserID      int
+		MockErr     error
+		ExpectedErr error
+		ExpectedUp  *bool
+	}{
+		{
+			Name:       "like",
+			Vote:       Like,
+			UserID:     1,
+			ExpectedUp: boolPtr(true),
+		},
+		{
+			Name:       "dislike",
+			Vote:       Dislike,
+			UserID:     1,
+			ExpectedUp: boolPtr(false),
+		},
+		{
+			Name:   "unvote",
+			Vote:   Unvote,
+			UserID: 1,
+		},
+		{
+			Name:        "anonymous like",
+			Vote:        Like,
+			UserID:      0,
+			ExpectedErr: ErrIncorrectVote,
+		},
+		{
+			Name:        "like of missing video",
+			Vote:        Like,
+			UserID:      1,
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_video_id", Field: "video_id"},
+			ExpectedErr: ErrNotFound,
+		},
+		{
+			Name:        "like of missing user",
+			Vote:        Like,
+			UserID:      1,
+			MockErr:     &storage.ConstraintError{Kind: storage.ErrConstraint, Constraint: "likes_fk_user_id", Field: "user_id"},
+			ExpectedErr: ErrIncorrectVote,
+		},
+		{
+			Name:        "timeout",
+			Vote:        Dislike,
+			UserID:      1,
+			MockErr:     fmt.Errorf("%w: statement timeout", storage.ErrQueryCanceled),
+			ExpectedErr: ErrTimeout,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			mock := &voteDBMock{err: tc.MockErr, votes: map[int]storage.Votes{7: {Likes: 3, Dislikes: 1}}}
+			votes, err := tc.Vote(context.Background(), mock, tc.UserID, 7)
+			if err := compareErrs(tc.ExpectedErr, err); err != nil {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if votes.VideoID != 7 || votes.Likes != 3 || votes.Dislikes != 1 {
+				t.Errorf("expected the counts of video 7, got %+v", votes)
+			}
+			if (tc.ExpectedUp == nil) != (mock.thumbUp == nil) ||
+				(tc.ExpectedUp != nil && *tc.ExpectedUp != *mock.thumbUp) {
+				t.Errorf("expected thumb up %v, got %v", tc.ExpectedUp, mock.thumbUp)
+			}
+		})
+	}
+}
+
+func TestGetVideosByCaptionVotes(t *testing.T) {
+	mock := &voteDBMock{
+		found: []*storage.FoundVideo{{ID: 1}, {ID: 2}, {ID: 3}},
+		votes: map[int]storage.Votes{1: {Likes: 5}, 3: {Dislikes: 2}},
+	}
+	res, err := GetVideosByCaption(context.Background(), mock, &SearchRequest{Phrase: "quis", Limit: 2}, DefaultPageLimits, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mock.countCalls != 1 {
+		t.Errorf("expected the votes to be counted with a single query, got %d", mock.countCalls)
+	}
+	if fmt.Sprint(mock.countedIDs) != "[1 2]" {
+		t.Errorf("expected the votes of the page videos only, got ids %v", mock.countedIDs)
+	}
+	if res.Videos[0].Likes != 5 || res.Videos[1].Likes != 0 || res.Videos[1].Dislikes != 0 {
+		t.Errorf("expected the counts to be set, got %+v and %+v", res.Videos[0].Votes, res.Videos[1].Votes)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// voteDBMock fails votes with err and counts them from votes
+type voteDBMock struct {
+	storage.DB
+
+	err        error
+	votes      map[int]storage.Votes
+	found      []*storage.FoundVideo
+	thumbUp    *bool
+	countCalls int
+	countedIDs []int
+}
+
+func (db *voteDBMock) Vote(ctx context.Context, userID int, videoID int, thumbUp bool) error {
+	db.thumbUp = &thumbUp
+	return db.err
+}
+
+func (db *voteDBMock) Unvote(ctx context.Context, userID int, videoID int) error {
+	return db.err
+}
+
+func (db *voteDBMock) CountVotes(ctx context.Context, videoIDs []int) (map[int]storage.Votes, error) {
+	db.countCalls++
+	db.countedIDs = videoIDs
+	return db.votes, nil
+}
+
+func (db *voteDBMock) GetVideosByCaption(ctx context.Context, q *storage.CaptionQuery) ([]*storage.FoundVideo, error) {
+	if len(db.found) > q.Limit {
+		return db.found[:q.Limit], nil
+	}
+	return db.found, nil
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Comment is a row of the comments table
+type Comment struct {
+	ID        int       `gorm:"column:id" json:"id"`
+	UserID    int       `gorm:"column:user_id" json:"user_id"`
+	VideoID   int       `gorm:"column:video_id" json:"video_id"`
+	Body      string    `gorm:"column:body" json:"body"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// CommentQuery describes one page of the comments of a video, a zero Limit means no limit
+type CommentQuery struct {
+	VideoID int
+	Asc     bool
+	Limit   int
+	After   *Cursor
+}
+
+const commentColumns = `id, user_id, video_id, body, created_at`
+
+// created_at is left to the DB default, see createVideoSQL
+const createCommentSQL = `INSERT INTO comments (user_id, video_id, body)
+	VALUES (?, ?, ?)
+	RETURNING ` + commentColumns
+
+const getCommentSQL = `SELECT ` + commentColumns + ` FROM comments WHERE id = ?`
+
+// the author condition makes editing and deleting someone else's comment a no-op
+const updateCommentSQL = `UPDATE comments SET body = ? WHERE id = ? AND user_id = ? RETURNING ` + commentColumns
+
+const deleteCommentSQL = `DELETE FROM comments WHERE id = ? AND user_id = ?`
+
+// countCommentsSQL returns no rows for a missing video, so it is told apart from a video without comments
+const countCommentsSQL = `SELECT count(comments.id)
+	FROM videos LEFT JOIN comments ON comments.video_id = videos.id
+	WHERE videos.id = ?
+	GROUP BY videos.id`
+
+// listCommentsSQL composes the query of a comments page, it is served by comments_video_id_created_at_idx
+func listCommentsSQL(q *CommentQuery) (string, []interface{}) {
+	dir := direction(q.Asc)
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE video_id = ?`
+	args := []interface{}{q.VideoID}
+	if q.After != nil {
+		cmp := "<"
+		if q.Asc {
+			cmp = ">"
+		}
+		query += fmt.Sprintf(` AND (created_at, id) %s (?, ?)`, cmp)
+		args = append(args, q.After.CreatedAt, q.After.ID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at %[1]s, id %[1]s`, dir)
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	return query, args
+}
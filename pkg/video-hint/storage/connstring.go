@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// ConnString locates the DB, unset fields are resolved the way libpq does it
+type ConnString struct {
+	URL         string
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	DBName      string
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	TimeZone    string
+}
+
+// Validate parses the connection string the way both drivers do
+func (c *ConnString) Validate() error {
+	dsn, err := composeGormDSN(c)
+	if err != nil {
+		return err
+	}
+	if _, err := pgconn.ParseConfig(dsn); err != nil {
+		return fmt.Errorf("invalid connection string: %w", err)
+	}
+	return nil
+}
+
+// settings merges the fields into the settings of URL, the keys are the libpq keywords
+func (c *ConnString) settings() (map[string]string, error) {
+	settings := make(map[string]string)
+	if c.URL != "" {
+		var err error
+		if strings.HasPrefix(c.URL, "postgres://") || strings.HasPrefix(c.URL, "postgresql://") {
+			settings, err = parseURLSettings(c.URL)
+		} else {
+			settings, err = parseDSNSettings(c.URL)
+		}
+		if err != nil {
+			// the URL holds the password, it must not get into the logs
+			return nil, fmt.Errorf("malformed connection URL: %w", err)
+		}
+	}
+	for key, val := range map[string]string{
+		"host":        c.Host,
+		"port":        c.Port,
+		"user":        c.User,
+		"password":    c.Password,
+		"dbname":      c.DBName,
+		"sslmode":     c.SSLMode,
+		"sslrootcert": c.SSLRootCert,
+		"sslcert":     c.SSLCert,
+		"sslkey":      c.SSLKey,
+		"timezone":    c.TimeZone,
+	} {
+		if val != "" {
+			settings[key] = val
+		}
+	}
+	return settings, nil
+}
+
+func composeConnectionString(c *ConnString) (string, error) {
+	settings, err := c.settings()
+	if err != nil {
+		return "", err
+	}
+	u := &url.URL{
+		Scheme: "postgresql",
+		Path:   "/" + settings["dbname"],
+	}
+	if user, ok := settings["user"]; ok {
+		if password, ok := settings["password"]; ok {
+			u.User = url.UserPassword(user, password)
+			delete(settings, "password")
+		} else {
+			u.User = url.User(user)
+		}
+		delete(settings, "user")
+	}
+	host, port := settings["host"], settings["port"]
+	if host != "" && !strings.ContainsAny(host, "/,") && !strings.Contains(port, ",") {
+		u.Host = host
+		if strings.Contains(host, ":") {
+			u.Host = "[" + host + "]"
+		}
+		if port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		}
+		delete(settings, "host")
+		delete(settings, "port")
+	}
+	delete(settings, "dbname")
+	query := url.Values{}
+	for key, val := range settings {
+		query.Set(key, val)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func composeGormDSN(c *ConnString) (string, error) {
+	settings, err := c.settings()
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+quoteDSNValue(settings[key]))
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+func quoteDSNValue(val string) string {
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, `'`, `\'`)
+	return "'" + val + "'"
+}
+
+// parseURLSettings reads a postgres:// URL the way pgconn does, keeping the libpq keywords
+func parseURLSettings(connString string) (map[string]string, error) {
+	u, err := url.Parse(connString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the URL")
+	}
+	settings := make(map[string]string)
+	if u.User != nil {
+		settings["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			settings["password"] = password
+		}
+	}
+	var hosts, ports []string
+	for _, hostPort := range strings.Split(u.Host, ",") {
+		if hostPort == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			// no port
+			host, port = strings.Trim(hostPort, "[]"), ""
+		}
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+		if port != "" {
+			ports = append(ports, port)
+		}
+	}
+	if len(hosts) > 0 {
+		settings["host"] = strings.Join(hosts, ",")
+	}
+	if len(ports) > 0 {
+		settings["port"] = strings.Join(ports, ",")
+	}
+	if dbName := strings.TrimPrefix(u.Path, "/"); dbName != "" {
+		settings["dbname"] = dbName
+	}
+	for key, vals := range u.Query() {
+		settings[key] = vals[0]
+	}
+	return settings, nil
+}
+
+func parseDSNSettings(s string) (map[string]string, error) {
+	settings := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\v\f")
+		if s == "" {
+			return settings, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing = after a key")
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " \t\n\r\v\f") {
+			return nil, fmt.Errorf("malformed key")
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\n\r\v\f")
+		quoted := strings.HasPrefix(s, "'")
+		if quoted {
+			s = s[1:]
+		}
+		var val strings.Builder
+		i := 0
+	value:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\':
+				i++
+				if i == len(s) {
+					return nil, fmt.Errorf("trailing backslash in the value of %s", key)
+				}
+				val.WriteByte(s[i])
+			case quoted && c == '\'':
+				break value
+			case !quoted && strings.IndexByte(" \t\n\r\v\f", c) >= 0:
+				break value
+			default:
+				val.WriteByte(c)
+			}
+		}
+		if quoted {
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated quoted value of %s", key)
+			}
+			i++
+		}
+		settings[key] = val.String()
+		s = s[i:]
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQueryCanceled = fmt.Errorf("the query was canceled or timed out")
+	ErrNotFound      = fmt.Errorf("the record is not found")
+	ErrConflict      = fmt.Errorf("the record conflicts with an existing one")
+	ErrConstraint    = fmt.Errorf("the record violates a constraint")
+)
+
+// Postgres error codes mapped to the storage errors
+const (
+	pgCodeQueryCanceled             = "57014"
+	pgCodeUniqueViolation           = "23505"
+	pgCodeForeignKeyViolation       = "23503"
+	pgCodeCheckViolation            = "23514"
+	pgCodeNotNullViolation          = "23502"
+	pgCodeInvalidTextRepresentation = "22P02"
+	pgCodeStringDataRightTruncation = "22001"
+)
+
+const statementTimeoutParam = "statement_timeout"
+
+// ConstraintError tells which constraint a write violated, errors.Is matches its Kind
+type ConstraintError struct {
+	Kind       error
+	Constraint string
+	Field      string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%v: field %s: %v", e.Kind, e.Field, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Kind
+}
+
+// constraintFields maps the constraints, which do not report a column, to the fields they guard
+var constraintFields = map[string]string{
+	"videos_location_key":       "location",
+	"videos_uri_key":            "uri",
+	"videos_fk_user_id":         "user_id",
+	"videos_valid_created_at":   "created_at",
+	"videos_valid_updated_at":   "updated_at",
+	"users_email_key":           "email",
+	"users_login_key":           "login",
+	"users_valid_birthday":      "birthday",
+	"comments_fk_user_id":       "user_id",
+	"comments_fk_video_id":      "video_id",
+	"comments_valid_created_at": "created_at",
+	"likes_fk_user_id":          "user_id",
+	"likes_fk_video_id":         "video_id",
+	"likes_user_id_fkey":        "user_id",
+	"likes_video_id_fkey":       "video_id",
+}
+
+// mapError translates the driver errors into the storage ones
+func mapError(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+	if ctx.Err() != nil || (errors.As(err, &pgErr) && pgErr.Code == pgCodeQueryCanceled) {
+		return fmt.Errorf("%w: %v", ErrQueryCanceled, err)
+	}
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	}
+	if pgErr == nil {
+		return err
+	}
+	var kind error
+	switch pgErr.Code {
+	case pgCodeUniqueViolation:
+		kind = ErrConflict
+	case pgCodeForeignKeyViolation, pgCodeCheckViolation, pgCodeNotNullViolation,
+		pgCodeInvalidTextRepresentation, pgCodeStringDataRightTruncation:
+		kind = ErrConstraint
+	default:
+		return err
+	}
+	field := pgErr.ColumnName
+	if f, ok := constraintFields[pgErr.ConstraintName]; ok {
+		field = f
+	}
+	return &ConstraintError{
+		Kind:       kind,
+		Constraint: pgErr.ConstraintName,
+		Field:      field,
+		Err:        err,
+	}
+}
+
+// formatStatementTimeout renders the timeout in milliseconds, the unit statement_timeout expects
+func formatStatementTimeout(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TextQuery describes one page of the full-text search, Phrase uses the web search syntax
+type TextQuery struct {
+	Phrase string
+	Asc    bool
+	Limit  int
+	Offset int
+}
+
+// fullTextSQL composes the query, the placeholders are: phrase, limit, offset
+func fullTextSQL(q *TextQuery) string {
+	limit := "ALL"
+	if q.Limit > 0 {
+		limit = "?"
+	}
+	// the text search configuration must match the one of videos.search_vector,
+	// the text is HTML escaped, so the highlighting is the only markup of the snippet
+	return fmt.Sprintf(`SELECT v.id, v.caption, v.uri, v.location, v.created_at, v.rank,
+			ts_headline('english', %[3]s, v.query,
+				'StartSel=<b>, StopSel=</b>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet
+		FROM (
+			SELECT videos.*, ts_rank(videos.search_vector, query) AS rank, query
+			FROM videos, websearch_to_tsquery('english', ?) AS query
+			WHERE videos.search_vector @@ query
+			ORDER BY rank %[1]s, videos.id %[1]s
+			LIMIT %[2]s OFFSET ?
+		) AS v
+		ORDER BY v.rank %[1]s, v.id %[1]s`,
+		direction(q.Asc), limit, htmlEscapeSQL(`v.caption || '. ' || v.description`),
+	)
+}
+
+// htmlEscapeSQL wraps a text expression escaping the HTML special characters
+func htmlEscapeSQL(expr string) string {
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&#34;"}, {"'", "&#39;"}} {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+	return expr
+}
+
+func fullTextArgs(q *TextQuery) []interface{} {
+	args := []interface{}{q.Phrase}
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+	}
+	return append(args, q.Offset)
+}
+
+// rebind turns the ? placeholders used by gorm into the $N ones used by pgx
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -2,70 +2,351 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"math"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Video is a row of the videos table, UpdatedAt is nil for videos that were never updated
 type Video struct {
-	ID          int       `gorm:"column:id"`
-	UserID      int       `gorm:"column:user_id"`
-	Location    string    `gorm:"column:location"`
-	URI         string    `gorm:"column:uri"`
-	RES         string    `gorm:"column:res"`
-	Caption     string    `gorm:"column:caption"`
-	Description string    `gorm:"column:description"`
-	CreatedAt   time.Time `gorm:"column:created_at"`
-	UpdatedAt   time.Time `gorm:"column:updated_at"`
+	ID          int        `gorm:"column:id" json:"id"`
+	UserID      int        `gorm:"column:user_id" json:"user_id"`
+	Location    string     `gorm:"column:location" json:"location"`
+	URI         string     `gorm:"column:uri" json:"uri"`
+	RES         string     `gorm:"column:res" json:"res"`
+	Caption     string     `gorm:"column:caption" json:"caption"`
+	Description string     `gorm:"column:description" json:"description"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   *time.Time `gorm:"column:updated_at" json:"updated_at"`
+	Votes       `gorm:"-"`
 }
 
 type gormDB struct {
-	db *gorm.DB
+	db    *gorm.DB
+	sqlDB *sql.DB
 }
 
-func newGormDB(c *ConnString) (*gormDB, error) {
-	db, err := gorm.Open(postgres.Open(composeGormDSN(c)), &gorm.Config{})
+func newGormDB(c *Config) (*gormDB, error) {
+	dsn, err := composeGormDSN(c.ConnString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose the DSN: %w", err)
+	}
+	dsn += fmt.Sprintf(" connect_timeout=%d", int(math.Ceil(connectTimeout(c).Seconds())))
+	if c.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" %s=%s", statementTimeoutParam, formatStatementTimeout(c.StatementTimeout))
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: &gormLogger{logger: logger(c)}})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open Gorm connection: %w", err)
 	}
+	if err := db.Use(gormTracing{}); err != nil {
+		return nil, fmt.Errorf("failed to register the tracing plugin: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the underlying sql.DB: %w", err)
+	}
+	setGormPoolConfig(sqlDB, c.Pool)
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to ping the DB: %w", err)
+	}
 	return &gormDB{
-		db: db,
+		db:    db,
+		sqlDB: sqlDB,
 	}, nil
 }
 
+// setGormPoolConfig maps the pool settings, database/sql has no minimum, so MinConns caps the idle ones
+func setGormPoolConfig(sqlDB *sql.DB, p PoolConfig) {
+	if p.MaxConns > 0 {
+		sqlDB.SetMaxOpenConns(int(p.MaxConns))
+	}
+	if p.MinConns > 0 {
+		sqlDB.SetMaxIdleConns(int(p.MinConns))
+	}
+	if p.MaxConnIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(p.MaxConnIdleTime)
+	}
+	if p.MaxConnLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(p.MaxConnLifetime)
+	}
+}
+
 // GetVideosByCaption sends query to the DB and processes the given result
-func (g *gormDB) GetVideosByCaption(ctx context.Context, phrase string) ([]*FoundVideo, error) {
+func (g *gormDB) GetVideosByCaption(ctx context.Context, q *CaptionQuery) ([]*FoundVideo, error) {
 	var vids []Video
-	req := g.db.
-		Select("caption", "uri", "location").
-		Where("caption LIKE ?", "%"+phrase+"%").
+	req := g.db.WithContext(ctx).
+		Select("id", "caption", "uri", "location", "created_at").
+		Where(captionMatchSQL(q), likeArg(q))
+	if q.After != nil {
+		req = req.Where(keysetCondition(q)+"(?, ?)", q.After.CreatedAt, q.After.ID)
+	}
+	order := clause.Expr{SQL: orderBy(q, "?"), WithoutParentheses: true}
+	if q.Sort == SortRelevance {
+		order.Vars = []interface{}{q.Phrase}
+	}
+	req = req.
+		Clauses(clause.OrderBy{Expression: order}).
+		Limit(q.Limit).
+		Offset(q.Offset).
 		Find(&vids)
 	if err := req.Error; err != nil {
-		return nil, fmt.Errorf("failed to query videos by caption substring: %w", err)
+		return nil, fmt.Errorf("failed to query videos by caption substring: %w", mapError(ctx, err))
 	}
 	videos := make([]*FoundVideo, len(vids))
 	for i, e := range vids {
 		p := &FoundVideo{
-			Caption:  e.Caption,
-			URI:      e.URI,
-			Location: e.Location,
+			ID:        e.ID,
+			Caption:   e.Caption,
+			URI:       e.URI,
+			Location:  e.Location,
+			CreatedAt: e.CreatedAt,
 		}
 		videos[i] = p
 	}
 	return videos, nil
 }
 
-func (g *gormDB) Close() {}
+// SearchVideos runs the full-text search over captions and descriptions
+func (g *gormDB) SearchVideos(ctx context.Context, q *TextQuery) ([]*FoundVideo, error) {
+	videos := make([]*FoundVideo, 0)
+	req := g.db.WithContext(ctx).Raw(fullTextSQL(q), fullTextArgs(q)...).Scan(&videos)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to run full-text search: %w", mapError(ctx, err))
+	}
+	return videos, nil
+}
+
+// GetVideosByCaptionSimilarity sets the threshold within a transaction, so it does not leak to the pool
+func (g *gormDB) GetVideosByCaptionSimilarity(ctx context.Context, q *SimilarityQuery) ([]*FoundVideo, error) {
+	videos := make([]*FoundVideo, 0)
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(similarityThresholdSQL, formatThreshold(q.Threshold)).Error; err != nil {
+			return fmt.Errorf("failed to set the similarity threshold: %w", err)
+		}
+		return tx.Raw(similaritySQL(q), similarityArgs(q)...).Scan(&videos).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run fuzzy caption search: %w", mapError(ctx, err))
+	}
+	return videos, nil
+}
+
+func (g *gormDB) CreateVideo(ctx context.Context, v *Video) (*Video, error) {
+	created := &Video{}
+	req := g.db.WithContext(ctx).Raw(createVideoSQL, createVideoArgs(v)...).Scan(created)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to create a video: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (g *gormDB) GetVideo(ctx context.Context, id int) (*Video, error) {
+	v := &Video{}
+	req := g.db.WithContext(ctx).Select(videoColumns).Take(v, id)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to get video %d: %w", id, mapError(ctx, err))
+	}
+	return v, nil
+}
+
+func (g *gormDB) UpdateVideo(ctx context.Context, id int, upd *VideoUpdate) (*Video, error) {
+	updated := &Video{}
+	req := g.db.WithContext(ctx).Raw(updateVideoSQL, updateVideoArgs(id, upd)...).Scan(updated)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to update video %d: %w", id, mapError(ctx, err))
+	}
+	if req.RowsAffected == 0 {
+		return nil, fmt.Errorf("failed to update video %d: %w", id, ErrNotFound)
+	}
+	return updated, nil
+}
+
+func (g *gormDB) DeleteVideo(ctx context.Context, id int) error {
+	req := g.db.WithContext(ctx).Delete(&Video{}, id)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to delete video %d: %w", id, mapError(ctx, err))
+	}
+	if req.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete video %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (g *gormDB) CreateUser(ctx context.Context, u *User) (*User, error) {
+	created := &User{}
+	req := g.db.WithContext(ctx).Raw(createUserSQL, createUserArgs(u)...).Scan(created)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to create a user: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (g *gormDB) GetUser(ctx context.Context, id int) (*User, error) {
+	u := &User{}
+	req := g.db.WithContext(ctx).Select(userColumns).Take(u, id)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", id, mapError(ctx, err))
+	}
+	return u, nil
+}
+
+func (g *gormDB) UpdateUser(ctx context.Context, id int, upd *UserUpdate) (*User, error) {
+	updated := &User{}
+	req := g.db.WithContext(ctx).Raw(updateUserSQL, updateUserArgs(id, upd)...).Scan(updated)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to update user %d: %w", id, mapError(ctx, err))
+	}
+	if req.RowsAffected == 0 {
+		return nil, fmt.Errorf("failed to update user %d: %w", id, ErrNotFound)
+	}
+	return updated, nil
+}
+
+func (g *gormDB) ListUsers(ctx context.Context, q *UserQuery) ([]*User, error) {
+	users := make([]*User, 0, q.Limit)
+	req := g.db.WithContext(ctx).Select(userColumns).Where("id > ?", q.AfterID).Order("id").Limit(q.Limit).Find(&users)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", mapError(ctx, err))
+	}
+	return users, nil
+}
+
+func (g *gormDB) CreateComment(ctx context.Context, c *Comment) (*Comment, error) {
+	created := &Comment{}
+	req := g.db.WithContext(ctx).Raw(createCommentSQL, c.UserID, c.VideoID, c.Body).Scan(created)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to create a comment: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (g *gormDB) GetComment(ctx context.Context, id int) (*Comment, error) {
+	c := &Comment{}
+	req := g.db.WithContext(ctx).Select(commentColumns).Take(c, id)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to get comment %d: %w", id, mapError(ctx, err))
+	}
+	return c, nil
+}
+
+func (g *gormDB) ListComments(ctx context.Context, q *CommentQuery) ([]*Comment, error) {
+	comments := make([]*Comment, 0, q.Limit)
+	query, args := listCommentsSQL(q)
+	req := g.db.WithContext(ctx).Raw(query, args...).Scan(&comments)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to list comments of video %d: %w", q.VideoID, mapError(ctx, err))
+	}
+	return comments, nil
+}
+
+func (g *gormDB) UpdateComment(ctx context.Context, id int, userID int, body string) (*Comment, error) {
+	updated := &Comment{}
+	req := g.db.WithContext(ctx).Raw(updateCommentSQL, body, id, userID).Scan(updated)
+	if err := req.Error; err != nil {
+		return nil, fmt.Errorf("failed to update comment %d: %w", id, mapError(ctx, err))
+	}
+	if req.RowsAffected == 0 {
+		return nil, fmt.Errorf("failed to update comment %d: %w", id, ErrNotFound)
+	}
+	return updated, nil
+}
+
+func (g *gormDB) DeleteComment(ctx context.Context, id int, userID int) error {
+	req := g.db.WithContext(ctx).Exec(deleteCommentSQL, id, userID)
+	if err := req.Error; err != nil {
+		return fmt.Errorf("failed to delete comment %d: %w", id, mapError(ctx, err))
+	}
+	if req.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete comment %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (g *gormDB) CountComments(ctx context.Context, videoID int) (int, error) {
+	var counts []int
+	req := g.db.WithContext(ctx).Raw(countCommentsSQL, videoID).Scan(&counts)
+	if err := req.Error; err != nil {
+		return 0, fmt.Errorf("failed to count comments of video %d: %w", videoID, mapError(ctx, err))
+	}
+	if len(counts) == 0 {
+		return 0, fmt.Errorf("failed to count comments of video %d: %w", videoID, ErrNotFound)
+	}
+	return counts[0], nil
+}
+
+func (g *gormDB) Vote(ctx context.Context, userID int, videoID int, thumbUp bool) error {
+	if err := g.db.WithContext(ctx).Exec(voteSQL, userID, videoID, thumbUp).Error; err != nil {
+		return fmt.Errorf("failed to vote for video %d: %w", videoID, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (g *gormDB) Unvote(ctx context.Context, userID int, videoID int) error {
+	if err := g.db.WithContext(ctx).Exec(unvoteSQL, userID, videoID).Error; err != nil {
+		return fmt.Errorf("failed to withdraw the vote for video %d: %w", videoID, mapError(ctx, err))
+	}
+	return nil
+}
+
+// CountVotes returns the votes of the videos having any, in a single query
+func (g *gormDB) CountVotes(ctx context.Context, videoIDs []int) (map[int]Votes, error) {
+	ids, err := videoIDsArg(videoIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind video ids: %w", err)
+	}
+	var rows []videoVotes
+	if err := g.db.WithContext(ctx).Raw(countVotesSQL, ids).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count votes: %w", mapError(ctx, err))
+	}
+	votes := make(map[int]Votes, len(rows))
+	for _, v := range rows {
+		votes[v.VideoID] = Votes{Likes: v.Likes, Dislikes: v.Dislikes}
+	}
+	return votes, nil
+}
+
+func (g *gormDB) Ping(ctx context.Context) error {
+	if err := g.sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping the DB: %w", mapError(ctx, err))
+	}
+	return nil
+}
+
+// Stats maps the database/sql stats, it keeps no connections being opened apart
+func (g *gormDB) Stats() PoolStats {
+	stat := g.sqlDB.Stats()
+	return PoolStats{
+		MaxConns:        int32(stat.MaxOpenConnections),
+		TotalConns:      int32(stat.OpenConnections),
+		AcquiredConns:   int32(stat.InUse),
+		IdleConns:       int32(stat.Idle),
+		WaitCount:       stat.WaitCount,
+		WaitDuration:    stat.WaitDuration,
+		HasWaitDuration: true,
+	}
+}
+
+func (g *gormDB) SchemaVersion(ctx context.Context) (int64, bool, error) {
+	var rows []struct {
+		Version int64
+		Dirty   bool
+	}
+	if err := g.db.WithContext(ctx).Raw(schemaVersionSQL).Scan(&rows).Error; err != nil {
+		return 0, false, schemaVersionError(ctx, err)
+	}
+	if len(rows) == 0 {
+		return 0, false, fmt.Errorf("failed to read the schema version: %w", ErrNoSchemaVersion)
+	}
+	return rows[0].Version, rows[0].Dirty, nil
+}
 
-func composeGormDSN(c *ConnString) string {
-	return fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Europe/Moscow",
-		c.Host,
-		c.User,
-		c.Password,
-		c.DBName,
-		c.Port,
-	)
+func (g *gormDB) Close() {
+	g.sqlDB.Close()
 }
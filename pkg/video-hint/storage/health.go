@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// PoolStats describes the connection pool, a zero MaxConns means no limit and WaitDuration
+// is only set if HasWaitDuration is
+type PoolStats struct {
+	MaxConns        int32         `json:"max_conns"`
+	TotalConns      int32         `json:"total_conns"`
+	AcquiredConns   int32         `json:"acquired_conns"`
+	IdleConns       int32         `json:"idle_conns"`
+	WaitCount       int64         `json:"wait_count"`
+	WaitDuration    time.Duration `json:"-"`
+	HasWaitDuration bool          `json:"-"`
+}
+
+// Saturated tells whether every connection the pool may open is busy, new queries wait then
+func (s PoolStats) Saturated() bool {
+	return s.MaxConns > 0 && s.AcquiredConns >= s.MaxConns
+}
+
+// ErrNoSchemaVersion means no migration has been applied
+var ErrNoSchemaVersion = fmt.Errorf("no migration is applied")
+
+// schemaVersionSQL reads the version the migrations record, see the migrate package
+const schemaVersionSQL = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+
+const pgCodeUndefinedTable = "42P01"
+
+func (c *conn) Ping(ctx context.Context) error {
+	if err := c.db.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping the DB: %w", mapError(ctx, err))
+	}
+	return nil
+}
+
+func (c *conn) Stats() PoolStats {
+	stat := c.db.Stat()
+	return PoolStats{
+		MaxConns:      stat.MaxConns(),
+		TotalConns:    stat.TotalConns(),
+		AcquiredConns: stat.AcquiredConns(),
+		IdleConns:     stat.IdleConns(),
+		// pgxpool only tracks the time of all acquires, so there is no wait duration
+		WaitCount: stat.EmptyAcquireCount(),
+	}
+}
+
+func (c *conn) SchemaVersion(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	if err := c.db.QueryRow(ctx, schemaVersionSQL).Scan(&version, &dirty); err != nil {
+		return 0, false, schemaVersionError(ctx, err)
+	}
+	return version, dirty, nil
+}
+
+// schemaVersionError reports a missing version table or row as ErrNoSchemaVersion
+func schemaVersionError(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgCodeUndefinedTable {
+		return fmt.Errorf("failed to read the schema version: %w", ErrNoSchemaVersion)
+	}
+	err = mapError(ctx, err)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to read the schema version: %w", ErrNoSchemaVersion)
+	}
+	return fmt.Errorf("failed to read the schema version: %w", err)
+}
@@ -0,0 +1,27 @@
+package storage
+
+import "strings"
+
+// likeEscaper escapes the LIKE metacharacters with the default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes LIKE match the string literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// captionMatchSQL matches the captions ignoring case and accents, the placeholder is bound to likeArg
+func captionMatchSQL(q *CaptionQuery) string {
+	if q.Pattern {
+		return `lower(immutable_unaccent(videos.caption)) LIKE lower(immutable_unaccent(?))`
+	}
+	return `lower(immutable_unaccent(videos.caption)) LIKE '%' || lower(immutable_unaccent(?)) || '%' ESCAPE '\'`
+}
+
+// likeArg is the phrase of the query, escaped unless it is a pattern
+func likeArg(q *CaptionQuery) string {
+	if q.Pattern {
+		return q.Phrase
+	}
+	return escapeLike(q.Phrase)
+}
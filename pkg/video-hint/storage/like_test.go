@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	cases := []struct {
+		Phrase   string
+		Expected string
+	}{
+		{
+			Phrase:   "interesting stuff",
+			Expected: "interesting stuff",
+		},
+		{
+			Phrase:   "100%",
+			Expected: `100\%`,
+		},
+		{
+			Phrase:   "snake_case",
+			Expected: `snake\_case`,
+		},
+		{
+			Phrase:   `C:\videos\%_`,
+			Expected: `C:\\videos\\\%\_`,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("case #%d", i), func(t *testing.T) {
+			if actual := escapeLike(tc.Phrase); actual != tc.Expected {
+				t.Errorf("expected %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestLikeArg(t *testing.T) {
+	if actual := likeArg(&CaptionQuery{Phrase: "50%_off"}); actual != `50\%\_off` {
+		t.Errorf("expected the phrase to be escaped, got %q", actual)
+	}
+	if actual := likeArg(&CaptionQuery{Phrase: "50%_off", Pattern: true}); actual != "50%_off" {
+		t.Errorf("expected the pattern to be kept, got %q", actual)
+	}
+}
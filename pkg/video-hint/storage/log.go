@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/log/logrusadapter"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/seggga/postgres/pkg/logging"
+)
+
+// requestEntry tags the log lines of a query with the ID of the request running it
+func requestEntry(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	entry := logrus.NewEntry(logger)
+	if id := logging.RequestID(ctx); id != "" {
+		entry = entry.WithField(logging.RequestIDField, id)
+	}
+	return entry
+}
+
+// pgxLogger passes the pgx logs to logrus the way logrusadapter does, with the request ID
+type pgxLogger struct {
+	logger *logrus.Logger
+}
+
+func (l *pgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	logrusadapter.NewLogger(requestEntry(ctx, l.logger)).Log(ctx, level, msg, data)
+}
+
+// gormLogger passes the gorm logs to logrus with the fields pgx uses
+type gormLogger struct {
+	logger *logrus.Logger
+}
+
+func (l *gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	requestEntry(ctx, l.logger).Infof(msg, args...)
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	requestEntry(ctx, l.logger).Warnf(msg, args...)
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	requestEntry(ctx, l.logger).Errorf(msg, args...)
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	if !failed && !l.logger.IsLevelEnabled(logrus.InfoLevel) {
+		return
+	}
+	sql, rows := fc()
+	entry := requestEntry(ctx, l.logger).WithFields(logrus.Fields{
+		"sql":      sql,
+		"time":     time.Since(begin),
+		"rowCount": rows,
+	})
+	if failed {
+		entry.WithField("err", err).Error("Query")
+		return
+	}
+	entry.Info("Query")
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/seggga/postgres/pkg/logging"
+)
+
+func TestQueryLogs(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, logrus.InfoLevel)
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	sql := func() (string, int64) { return "SELECT 1", 1 }
+
+	(&pgxLogger{logger: logger}).Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1"})
+	(&pgxLogger{logger: logger}).Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1"})
+	(&gormLogger{logger: logger}).Trace(ctx, time.Now(), sql, nil)
+	(&gormLogger{logger: logger}).Trace(ctx, time.Now(), sql, gorm.ErrRecordNotFound)
+	(&gormLogger{logger: logger}).Trace(ctx, time.Now(), sql, fmt.Errorf("connection reset"))
+
+	cases := []struct {
+		Name      string
+		RequestID interface{}
+		Level     string
+	}{
+		{Name: "pgx", RequestID: "req-1", Level: "info"},
+		{Name: "pgx outside of requests", RequestID: nil, Level: "info"},
+		{Name: "gorm", RequestID: "req-1", Level: "info"},
+		{Name: "gorm not found", RequestID: "req-1", Level: "info"},
+		{Name: "gorm failed", RequestID: "req-1", Level: "error"},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(cases) {
+		t.Fatalf("expected %d log lines, got: %q", len(cases), out.String())
+	}
+	for i, tc := range cases {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &fields); err != nil {
+			t.Fatalf("failed to parse the log line %q: %v", lines[i], err)
+		}
+		if fields[logging.RequestIDField] != tc.RequestID || fields["level"] != tc.Level || fields["sql"] != "SELECT 1" {
+			t.Errorf("%s: expected a %s line with request ID %v, got: %s", tc.Name, tc.Level, tc.RequestID, lines[i])
+		}
+	}
+
+	out.Reset()
+	logger.SetLevel(logrus.WarnLevel)
+	(&gormLogger{logger: logger}).Trace(ctx, time.Now(), sql, nil)
+	if out.Len() != 0 {
+		t.Errorf("expected the queries not to be logged above the info level, got: %s", out.String())
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// QueryObserver is told how long every storage call took and how it ended
+type QueryObserver interface {
+	ObserveQuery(backend Driver, method string, d time.Duration, err error)
+}
+
+// observedDB times the queries of a DB, the health checks and Close are passed through
+type observedDB struct {
+	DB
+	backend  Driver
+	observer QueryObserver
+}
+
+func observe(db DB, backend Driver, observer QueryObserver) DB {
+	return &observedDB{
+		DB:       db,
+		backend:  backend,
+		observer: observer,
+	}
+}
+
+func (o *observedDB) observe(method string, start time.Time, err *error) {
+	o.observer.ObserveQuery(o.backend, method, time.Since(start), *err)
+}
+
+func (o *observedDB) GetVideosByCaption(ctx context.Context, q *CaptionQuery) (_ []*FoundVideo, err error) {
+	defer o.observe("GetVideosByCaption", time.Now(), &err)
+	return o.DB.GetVideosByCaption(ctx, q)
+}
+
+func (o *observedDB) SearchVideos(ctx context.Context, q *TextQuery) (_ []*FoundVideo, err error) {
+	defer o.observe("SearchVideos", time.Now(), &err)
+	return o.DB.SearchVideos(ctx, q)
+}
+
+func (o *observedDB) GetVideosByCaptionSimilarity(ctx context.Context, q *SimilarityQuery) (_ []*FoundVideo, err error) {
+	defer o.observe("GetVideosByCaptionSimilarity", time.Now(), &err)
+	return o.DB.GetVideosByCaptionSimilarity(ctx, q)
+}
+
+func (o *observedDB) CreateVideo(ctx context.Context, v *Video) (_ *Video, err error) {
+	defer o.observe("CreateVideo", time.Now(), &err)
+	return o.DB.CreateVideo(ctx, v)
+}
+
+func (o *observedDB) GetVideo(ctx context.Context, id int) (_ *Video, err error) {
+	defer o.observe("GetVideo", time.Now(), &err)
+	return o.DB.GetVideo(ctx, id)
+}
+
+func (o *observedDB) UpdateVideo(ctx context.Context, id int, upd *VideoUpdate) (_ *Video, err error) {
+	defer o.observe("UpdateVideo", time.Now(), &err)
+	return o.DB.UpdateVideo(ctx, id, upd)
+}
+
+func (o *observedDB) DeleteVideo(ctx context.Context, id int) (err error) {
+	defer o.observe("DeleteVideo", time.Now(), &err)
+	return o.DB.DeleteVideo(ctx, id)
+}
+
+func (o *observedDB) CreateUser(ctx context.Context, u *User) (_ *User, err error) {
+	defer o.observe("CreateUser", time.Now(), &err)
+	return o.DB.CreateUser(ctx, u)
+}
+
+func (o *observedDB) GetUser(ctx context.Context, id int) (_ *User, err error) {
+	defer o.observe("GetUser", time.Now(), &err)
+	return o.DB.GetUser(ctx, id)
+}
+
+func (o *observedDB) UpdateUser(ctx context.Context, id int, upd *UserUpdate) (_ *User, err error) {
+	defer o.observe("UpdateUser", time.Now(), &err)
+	return o.DB.UpdateUser(ctx, id, upd)
+}
+
+func (o *observedDB) ListUsers(ctx context.Context, q *UserQuery) (_ []*User, err error) {
+	defer o.observe("ListUsers", time.Now(), &err)
+	return o.DB.ListUsers(ctx, q)
+}
+
+func (o *observedDB) CreateComment(ctx context.Context, c *Comment) (_ *Comment, err error) {
+	defer o.observe("CreateComment", time.Now(), &err)
+	return o.DB.CreateComment(ctx, c)
+}
+
+func (o *observedDB) GetComment(ctx context.Context, id int) (_ *Comment, err error) {
+	defer o.observe("GetComment", time.Now(), &err)
+	return o.DB.GetComment(ctx, id)
+}
+
+func (o *observedDB) ListComments(ctx context.Context, q *CommentQuery) (_ []*Comment, err error) {
+	defer o.observe("ListComments", time.Now(), &err)
+	return o.DB.ListComments(ctx, q)
+}
+
+func (o *observedDB) UpdateComment(ctx context.Context, id int, userID int, body string) (_ *Comment, err error) {
+	defer o.observe("UpdateComment", time.Now(), &err)
+	return o.DB.UpdateComment(ctx, id, userID, body)
+}
+
+func (o *observedDB) DeleteComment(ctx context.Context, id int, userID int) (err error) {
+	defer o.observe("DeleteComment", time.Now(), &err)
+	return o.DB.DeleteComment(ctx, id, userID)
+}
+
+func (o *observedDB) CountComments(ctx context.Context, videoID int) (_ int, err error) {
+	defer o.observe("CountComments", time.Now(), &err)
+	return o.DB.CountComments(ctx, videoID)
+}
+
+func (o *observedDB) Vote(ctx context.Context, userID int, videoID int, thumbUp bool) (err error) {
+	defer o.observe("Vote", time.Now(), &err)
+	return o.DB.Vote(ctx, userID, videoID, thumbUp)
+}
+
+func (o *observedDB) Unvote(ctx context.Context, userID int, videoID int) (err error) {
+	defer o.observe("Unvote", time.Now(), &err)
+	return o.DB.Unvote(ctx, userID, videoID)
+}
+
+func (o *observedDB) CountVotes(ctx context.Context, videoIDs []int) (_ map[int]Votes, err error) {
+	defer o.observe("CountVotes", time.Now(), &err)
+	return o.DB.CountVotes(ctx, videoIDs)
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	obs := &queryObserverMock{}
+	db := observe(&observeDBMock{err: ErrNotFound}, DriverGorm, obs)
+
+	_, err := db.GetVideo(context.Background(), 1)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the DB error to be passed through, got: %v", err)
+	}
+	if obs.backend != DriverGorm || obs.method != "GetVideo" || !errors.Is(obs.err, ErrNotFound) {
+		t.Errorf("unexpected observation: %+v", *obs)
+	}
+	if obs.d < time.Millisecond {
+		t.Errorf("expected the query time to be measured, got: %v", obs.d)
+	}
+
+	*obs = queryObserverMock{}
+	db.Close()
+	if obs.method != "" {
+		t.Errorf("expected Close not to be observed, got: %s", obs.method)
+	}
+}
+
+type observeDBMock struct {
+	DB
+
+	err error
+}
+
+func (db *observeDBMock) GetVideo(ctx context.Context, id int) (*Video, error) {
+	time.Sleep(time.Millisecond)
+	return nil, db.err
+}
+
+func (db *observeDBMock) Close() {}
+
+type queryObserverMock struct {
+	backend Driver
+	method  string
+	d       time.Duration
+	err     error
+}
+
+func (o *queryObserverMock) ObserveQuery(backend Driver, method string, d time.Duration, err error) {
+	*o = queryObserverMock{backend, method, d, err}
+}
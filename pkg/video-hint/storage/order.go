@@ -0,0 +1,56 @@
+package storage
+
+import "fmt"
+
+// SortField names the value the caption search results are ordered by
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortUpdatedAt SortField = "updated_at"
+	SortRelevance SortField = "relevance"
+	SortLikes     SortField = "likes"
+)
+
+// Keyset tells whether the sort can be paginated with a Cursor, only created_at has the index for it
+func (f SortField) Keyset() bool {
+	return f == "" || f == SortCreatedAt
+}
+
+// orderBy composes the ORDER BY of the caption search, phraseArg is the placeholder of the phrase
+func orderBy(q *CaptionQuery, phraseArg string) string {
+	dir := direction(q.Asc)
+	switch q.Sort {
+	case SortUpdatedAt:
+		return fmt.Sprintf("videos.updated_at %[1]s NULLS LAST, videos.id %[1]s", dir)
+	case SortRelevance:
+		// an earlier match in a shorter caption is more relevant
+		return fmt.Sprintf(
+			"strpos(lower(immutable_unaccent(videos.caption)), lower(immutable_unaccent(%[1]s))) %[2]s, "+
+				"length(videos.caption) %[2]s, videos.id %[3]s",
+			phraseArg, direction(!q.Asc), dir,
+		)
+	case SortLikes:
+		return fmt.Sprintf(
+			"(SELECT count(*) FROM likes WHERE likes.video_id = videos.id AND likes.thumb_up) %[1]s, videos.id %[1]s",
+			dir,
+		)
+	default:
+		return fmt.Sprintf("videos.created_at %[1]s, videos.id %[1]s", dir)
+	}
+}
+
+// keysetCondition selects the rows following the cursor in the sort direction
+func keysetCondition(q *CaptionQuery) string {
+	if q.Asc {
+		return "(videos.created_at, videos.id) > "
+	}
+	return "(videos.created_at, videos.id) < "
+}
+
+func direction(asc bool) string {
+	if asc {
+		return "ASC"
+	}
+	return "DESC"
+}
@@ -3,107 +3,457 @@ package storage
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"os"
-	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v4/log/logrusadapter"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
-type ContextKey int
+// FoundVideo is a search result, Rank, Snippet and Score are set by the searches ranking them
+type FoundVideo struct {
+	ID        int       `json:"id"`
+	Caption   string    `json:"caption"`
+	URI       string    `json:"uri"`
+	Location  string    `json:"location"`
+	CreatedAt time.Time `json:"created_at"`
+	Rank      float32   `json:"rank,omitempty"`
+	Snippet   string    `json:"snippet,omitempty"`
+	Score     float32   `json:"score,omitempty"`
+	Votes
+}
 
-const ContextKeyDB ContextKey = iota + 1
+// Cursor points at the last video or comment of the previous page, it is only valid for keyset sorts
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
 
-var (
-	db    *pgxpool.Pool
-	dbMux = &sync.Mutex{}
-)
+// CaptionQuery describes one page of the caption search, Phrase is matched literally unless Pattern is set
+type CaptionQuery struct {
+	Phrase  string
+	Pattern bool
+	Sort    SortField
+	Asc     bool
+	Limit   int
+	Offset  int
+	After   *Cursor
+}
 
-type FoundVideo struct {
-	Caption  string `json:"caption"`
-	URI      string `json:"uri"`
-	Location string `json:"location"`
+type DB interface {
+	GetVideosByCaption(ctx context.Context, q *CaptionQuery) ([]*FoundVideo, error)
+	SearchVideos(ctx context.Context, q *TextQuery) ([]*FoundVideo, error)
+	GetVideosByCaptionSimilarity(ctx context.Context, q *SimilarityQuery) ([]*FoundVideo, error)
+	CreateVideo(ctx context.Context, v *Video) (*Video, error)
+	GetVideo(ctx context.Context, id int) (*Video, error)
+	UpdateVideo(ctx context.Context, id int, upd *VideoUpdate) (*Video, error)
+	DeleteVideo(ctx context.Context, id int) error
+	CreateUser(ctx context.Context, u *User) (*User, error)
+	GetUser(ctx context.Context, id int) (*User, error)
+	UpdateUser(ctx context.Context, id int, upd *UserUpdate) (*User, error)
+	ListUsers(ctx context.Context, q *UserQuery) ([]*User, error)
+	CreateComment(ctx context.Context, c *Comment) (*Comment, error)
+	GetComment(ctx context.Context, id int) (*Comment, error)
+	ListComments(ctx context.Context, q *CommentQuery) ([]*Comment, error)
+	UpdateComment(ctx context.Context, id int, userID int, body string) (*Comment, error)
+	DeleteComment(ctx context.Context, id int, userID int) error
+	CountComments(ctx context.Context, videoID int) (int, error)
+	Vote(ctx context.Context, userID int, videoID int, thumbUp bool) error
+	Unvote(ctx context.Context, userID int, videoID int) error
+	CountVotes(ctx context.Context, videoIDs []int) (map[int]Votes, error)
+	Ping(ctx context.Context) error
+	Stats() PoolStats
+	SchemaVersion(ctx context.Context) (version int64, dirty bool, err error)
+	Close()
 }
 
-/*
+// PoolConfig holds the connection pool settings, zero values keep the driver defaults
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnIdleTime time.Duration
+	MaxConnLifetime time.Duration
+}
 
-try then
+// Driver names a DB implementation
+type Driver string
 
-*/
+const (
+	DriverPGX  Driver = "pgx"
+	DriverGorm Driver = "gorm"
+)
 
-type DB interface {
-	GetVideosByCaption(ctx context.Context, prefix string) ([]*FoundVideo, error)
-	Close()
+var ErrUnknownDriver = fmt.Errorf("unknown DB driver")
+
+// ParseDriver validates the driver name, an empty name selects pgx
+func ParseDriver(name string) (Driver, error) {
+	switch d := Driver(name); d {
+	case "":
+		return DriverPGX, nil
+	case DriverPGX, DriverGorm:
+		return d, nil
+	default:
+		return "", fmt.Errorf("%w: %q, expected %q or %q", ErrUnknownDriver, name, DriverPGX, DriverGorm)
+	}
 }
 
-type ConnString struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+// Config describes how the storage connects to the DB, Logger and Observer may be nil
+type Config struct {
+	Driver           Driver
+	ConnString       *ConnString
+	Pool             PoolConfig
+	ConnectTimeout   time.Duration
+	StatementTimeout time.Duration
+	Logger           *logrus.Logger
+	Observer         QueryObserver
 }
 
-func NewDB(connStr *ConnString) (DB, error) {
-	// pool, err := getConn(connStr)
-	// if err != nil {
-	// 	return nil, fmt.Errorf("failed to get a connection pool: %w", err)
-	// }
-	// return &conn{
-	// 	db: pool,
-	// }, nil
-	gormDB, err := newGormDB(connStr)
+const defaultConnectTimeout = time.Second * 1
+
+// NewDB creates the pool shared by all requests, it is released by DB.Close
+func NewDB(cfg *Config) (DB, error) {
+	driver, err := ParseDriver(string(cfg.Driver))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+		return nil, err
 	}
-	return gormDB, nil
+	var db DB
+	if driver == DriverGorm {
+		gormDB, err := newGormDB(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open a gorm connection: %w", err)
+		}
+		db = gormDB
+	} else {
+		pool, err := getConn(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a connection pool: %w", err)
+		}
+		db = &conn{
+			db: &tracedPool{Pool: pool},
+		}
+	}
+	if cfg.Observer != nil {
+		db = observe(db, driver, cfg.Observer)
+	}
+	return db, nil
 }
 
 type conn struct {
-	db *pgxpool.Pool
+	db *tracedPool
 }
 
 // GetVideosByCaption sends query to the DB and processes the given result
-func (c *conn) GetVideosByCaption(ctx context.Context, phrase string) ([]*FoundVideo, error) {
-	rows, err := c.db.Query(
-		context.Background(),
-		`SELECT caption, uri, location
+func (c *conn) GetVideosByCaption(ctx context.Context, q *CaptionQuery) ([]*FoundVideo, error) {
+	query := `SELECT id, caption, uri, location, created_at
 		FROM videos
-		WHERE caption LIKE '%' || $1 || '%'`,
-		phrase,
-	)
+		WHERE ` + rebind(captionMatchSQL(q))
+	args := []interface{}{likeArg(q)}
+	if q.After != nil {
+		query += ` AND ` + keysetCondition(q) + `($2, $3)`
+		args = append(args, q.After.CreatedAt, q.After.ID)
+	}
+	phraseArg := ""
+	if q.Sort == SortRelevance {
+		// the relevance looks for the phrase itself, not for the escaped LIKE argument
+		args = append(args, q.Phrase)
+		phraseArg = fmt.Sprintf("$%d", len(args))
+	}
+	query += ` ORDER BY ` + orderBy(q, phraseArg)
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if q.Offset > 0 {
+		args = append(args, q.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	rows, err := c.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return nil, fmt.Errorf("query failed: %w", mapError(ctx, err))
 	}
 	defer rows.Close()
 
 	videos := make([]*FoundVideo, 0)
 	for rows.Next() {
 		v := &FoundVideo{}
-		if err := rows.Scan(&v.Caption, &v.URI, &v.Location); err != nil {
+		if err := rows.Scan(&v.ID, &v.Caption, &v.URI, &v.Location, &v.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to get rows on given phrase: %w", err)
 		}
 		videos = append(videos, v)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows on given phrase: %w", mapError(ctx, err))
+	}
 	return videos, nil
 }
 
-func (c *conn) Close() {
-	c.db.Close()
+// SearchVideos runs the full-text search over captions and descriptions
+func (c *conn) SearchVideos(ctx context.Context, q *TextQuery) ([]*FoundVideo, error) {
+	rows, err := c.db.Query(ctx, rebind(fullTextSQL(q)), fullTextArgs(q)...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", mapError(ctx, err))
+	}
+	defer rows.Close()
+
+	videos := make([]*FoundVideo, 0)
+	for rows.Next() {
+		v := &FoundVideo{}
+		if err := rows.Scan(&v.ID, &v.Caption, &v.URI, &v.Location, &v.CreatedAt, &v.Rank, &v.Snippet); err != nil {
+			return nil, fmt.Errorf("failed to get rows on given phrase: %w", err)
+		}
+		videos = append(videos, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows on given phrase: %w", mapError(ctx, err))
+	}
+	return videos, nil
+}
+
+// GetVideosByCaptionSimilarity sets the threshold within a transaction, so it does not leak to the pool
+func (c *conn) GetVideosByCaptionSimilarity(ctx context.Context, q *SimilarityQuery) ([]*FoundVideo, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin a transaction: %w", mapError(ctx, err))
+	}
+	// the rollback has to reach the DB even if ctx is already done
+	defer tx.Rollback(context.Background())
+	if _, err := tx.Exec(ctx, rebind(similarityThresholdSQL), formatThreshold(q.Threshold)); err != nil {
+		return nil, fmt.Errorf("failed to set the similarity threshold: %w", mapError(ctx, err))
+	}
+	rows, err := tx.Query(ctx, rebind(similaritySQL(q)), similarityArgs(q)...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", mapError(ctx, err))
+	}
+	defer rows.Close()
+
+	videos := make([]*FoundVideo, 0)
+	for rows.Next() {
+		v := &FoundVideo{}
+		if err := rows.Scan(&v.ID, &v.Caption, &v.URI, &v.Location, &v.CreatedAt, &v.Score); err != nil {
+			return nil, fmt.Errorf("failed to get rows on given phrase: %w", err)
+		}
+		videos = append(videos, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows on given phrase: %w", mapError(ctx, err))
+	}
+	return videos, nil
+}
+
+func (c *conn) CreateVideo(ctx context.Context, v *Video) (*Video, error) {
+	created, err := scanVideo(c.db.QueryRow(ctx, rebind(createVideoSQL), createVideoArgs(v)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a video: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (c *conn) GetVideo(ctx context.Context, id int) (*Video, error) {
+	v, err := scanVideo(c.db.QueryRow(ctx, rebind(getVideoSQL), id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video %d: %w", id, mapError(ctx, err))
+	}
+	return v, nil
+}
+
+func (c *conn) UpdateVideo(ctx context.Context, id int, upd *VideoUpdate) (*Video, error) {
+	v, err := scanVideo(c.db.QueryRow(ctx, rebind(updateVideoSQL), updateVideoArgs(id, upd)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update video %d: %w", id, mapError(ctx, err))
+	}
+	return v, nil
+}
+
+func (c *conn) DeleteVideo(ctx context.Context, id int) error {
+	tag, err := c.db.Exec(ctx, rebind(deleteVideoSQL), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete video %d: %w", id, mapError(ctx, err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete video %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func scanVideo(row pgx.Row) (*Video, error) {
+	v := &Video{}
+	err := row.Scan(&v.ID, &v.UserID, &v.Location, &v.URI, &v.RES, &v.Caption, &v.Description, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *conn) CreateUser(ctx context.Context, u *User) (*User, error) {
+	created, err := scanUser(c.db.QueryRow(ctx, rebind(createUserSQL), createUserArgs(u)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a user: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (c *conn) GetUser(ctx context.Context, id int) (*User, error) {
+	u, err := scanUser(c.db.QueryRow(ctx, rebind(getUserSQL), id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", id, mapError(ctx, err))
+	}
+	return u, nil
+}
+
+func (c *conn) UpdateUser(ctx context.Context, id int, upd *UserUpdate) (*User, error) {
+	u, err := scanUser(c.db.QueryRow(ctx, rebind(updateUserSQL), updateUserArgs(id, upd)...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user %d: %w", id, mapError(ctx, err))
+	}
+	return u, nil
+}
+
+func (c *conn) ListUsers(ctx context.Context, q *UserQuery) ([]*User, error) {
+	rows, err := c.db.Query(ctx, rebind(listUsersSQL), q.AfterID, q.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", mapError(ctx, err))
+	}
+	defer rows.Close()
+	users := make([]*User, 0, q.Limit)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read a user: %w", mapError(ctx, err))
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", mapError(ctx, err))
+	}
+	return users, nil
+}
+
+func scanUser(row pgx.Row) (*User, error) {
+	u := &User{}
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Login, &u.Birthday, &u.About); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (c *conn) CreateComment(ctx context.Context, cm *Comment) (*Comment, error) {
+	created, err := scanComment(c.db.QueryRow(ctx, rebind(createCommentSQL), cm.UserID, cm.VideoID, cm.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a comment: %w", mapError(ctx, err))
+	}
+	return created, nil
+}
+
+func (c *conn) GetComment(ctx context.Context, id int) (*Comment, error) {
+	cm, err := scanComment(c.db.QueryRow(ctx, rebind(getCommentSQL), id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment %d: %w", id, mapError(ctx, err))
+	}
+	return cm, nil
+}
+
+func (c *conn) ListComments(ctx context.Context, q *CommentQuery) ([]*Comment, error) {
+	query, args := listCommentsSQL(q)
+	rows, err := c.db.Query(ctx, rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of video %d: %w", q.VideoID, mapError(ctx, err))
+	}
+	defer rows.Close()
+	comments := make([]*Comment, 0, q.Limit)
+	for rows.Next() {
+		cm, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read a comment: %w", mapError(ctx, err))
+		}
+		comments = append(comments, cm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list comments of video %d: %w", q.VideoID, mapError(ctx, err))
+	}
+	return comments, nil
 }
 
-func getConn(connStr *ConnString) (*pgxpool.Pool, error) {
-	dbMux.Lock()
-	defer dbMux.Unlock()
-	if db != nil {
-		return db, nil
+func (c *conn) UpdateComment(ctx context.Context, id int, userID int, body string) (*Comment, error) {
+	cm, err := scanComment(c.db.QueryRow(ctx, rebind(updateCommentSQL), body, id, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment %d: %w", id, mapError(ctx, err))
 	}
+	return cm, nil
+}
 
-	var err error
-	db, err = initPGXPool(connStr)
+func (c *conn) DeleteComment(ctx context.Context, id int, userID int) error {
+	tag, err := c.db.Exec(ctx, rebind(deleteCommentSQL), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment %d: %w", id, mapError(ctx, err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to delete comment %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (c *conn) CountComments(ctx context.Context, videoID int) (int, error) {
+	var count int
+	if err := c.db.QueryRow(ctx, rebind(countCommentsSQL), videoID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count comments of video %d: %w", videoID, mapError(ctx, err))
+	}
+	return count, nil
+}
+
+func scanComment(row pgx.Row) (*Comment, error) {
+	cm := &Comment{}
+	if err := row.Scan(&cm.ID, &cm.UserID, &cm.VideoID, &cm.Body, &cm.CreatedAt); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+func (c *conn) Vote(ctx context.Context, userID int, videoID int, thumbUp bool) error {
+	if _, err := c.db.Exec(ctx, rebind(voteSQL), userID, videoID, thumbUp); err != nil {
+		return fmt.Errorf("failed to vote for video %d: %w", videoID, mapError(ctx, err))
+	}
+	return nil
+}
+
+func (c *conn) Unvote(ctx context.Context, userID int, videoID int) error {
+	if _, err := c.db.Exec(ctx, rebind(unvoteSQL), userID, videoID); err != nil {
+		return fmt.Errorf("failed to withdraw the vote for video %d: %w", videoID, mapError(ctx, err))
+	}
+	return nil
+}
+
+// CountVotes returns the votes of the videos having any, in a single query
+func (c *conn) CountVotes(ctx context.Context, videoIDs []int) (map[int]Votes, error) {
+	ids, err := videoIDsArg(videoIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind video ids: %w", err)
+	}
+	rows, err := c.db.Query(ctx, rebind(countVotesSQL), ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count votes: %w", mapError(ctx, err))
+	}
+	defer rows.Close()
+	votes := make(map[int]Votes, len(videoIDs))
+	for rows.Next() {
+		var v videoVotes
+		if err := rows.Scan(&v.VideoID, &v.Likes, &v.Dislikes); err != nil {
+			return nil, fmt.Errorf("failed to read votes: %w", err)
+		}
+		votes[v.VideoID] = Votes{Likes: v.Likes, Dislikes: v.Dislikes}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count votes: %w", mapError(ctx, err))
+	}
+	return votes, nil
+}
+
+func (c *conn) Close() {
+	c.db.Close()
+}
+
+func getConn(cfg *Config) (*pgxpool.Pool, error) {
+	db, err := initPGXPool(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize a PGX pool: %w", err)
 	}
@@ -114,47 +464,78 @@ func getConn(connStr *ConnString) (*pgxpool.Pool, error) {
 	return db, nil
 }
 
-func initPGXPool(c *ConnString) (*pgxpool.Pool, error) {
-	connStr, err := composeConnectionString(c)
+func initPGXPool(c *Config) (*pgxpool.Pool, error) {
+	connStr, err := composeConnectionString(c.ConnString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compose the connection string: %w", err)
 	}
-	cfg, err := getPGXPoolConfig(connStr)
+	cfg, err := getPGXPoolConfig(connStr, c)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get the PGX pool config: %w", err)
 	}
-	db, err = pgxpool.ConnectConfig(context.Background(), cfg)
+	if c.StatementTimeout > 0 {
+		cfg.ConnConfig.RuntimeParams[statementTimeoutParam] = formatStatementTimeout(c.StatementTimeout)
+	}
+	db, err := pgxpool.ConnectConfig(context.Background(), cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the postgres DB using a PGX connection pool: %w", err)
 	}
 	return db, nil
 }
 
-func getPGXPoolConfig(connStr string) (*pgxpool.Config, error) {
+func getPGXPoolConfig(connStr string, c *Config) (*pgxpool.Config, error) {
 	cfg, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the PGX pool config from connection string: %w", err)
 	}
-	cfg.ConnConfig.ConnectTimeout = time.Second * 1
-	cfg.ConnConfig.Logger = logrusadapter.NewLogger(
-		&logrus.Logger{
-			Out:          os.Stdout,
-			Formatter:    new(logrus.JSONFormatter),
-			Hooks:        make(logrus.LevelHooks),
-			Level:        logrus.InfoLevel,
-			ExitFunc:     os.Exit,
-			ReportCaller: false,
-		})
+	p := c.Pool
+	if p.MaxConns > 0 {
+		cfg.MaxConns = p.MaxConns
+	}
+	if p.MinConns > 0 {
+		cfg.MinConns = p.MinConns
+	}
+	if p.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = p.MaxConnIdleTime
+	}
+	if p.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = p.MaxConnLifetime
+	}
+	cfg.ConnConfig.ConnectTimeout = connectTimeout(c)
+	cfg.ConnConfig.Logger = &pgxLogger{logger: logger(c)}
 	return cfg, nil
 }
 
-func composeConnectionString(c *ConnString) (string, error) {
-	return fmt.Sprintf(
-		"postgresql://%s:%s@%s:%s/%s",
-		url.QueryEscape(c.User),
-		url.QueryEscape(c.Password),
-		url.QueryEscape(c.Host),
-		url.QueryEscape(c.Port),
-		url.QueryEscape(c.DBName),
-	), nil
+func connectTimeout(c *Config) time.Duration {
+	if c.ConnectTimeout > 0 {
+		return c.ConnectTimeout
+	}
+	return defaultConnectTimeout
+}
+
+func logger(c *Config) *logrus.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return &logrus.Logger{
+		Out:          os.Stdout,
+		Formatter:    new(logrus.JSONFormatter),
+		Hooks:        make(logrus.LevelHooks),
+		Level:        logrus.InfoLevel,
+		ExitFunc:     os.Exit,
+		ReportCaller: false,
+	}
+}
+
+// ConnectPGX opens a single connection outside of the pool for session state like advisory locks
+func ConnectPGX(ctx context.Context, c *ConnString) (*pgx.Conn, error) {
+	connStr, err := composeConnectionString(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose the connection string: %w", err)
+	}
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the postgres DB: %w", err)
+	}
+	return conn, nil
 }
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SimilarityQuery describes one page of the fuzzy caption search, Threshold is the minimal word similarity
+type SimilarityQuery struct {
+	Phrase    string
+	Threshold float64
+	Asc       bool
+	Limit     int
+	Offset    int
+}
+
+// similarityThresholdSQL sets the threshold of <% for the current transaction
+const similarityThresholdSQL = `SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)`
+
+// similaritySQL composes the query, the placeholders are: phrase, phrase, limit, offset
+func similaritySQL(q *SimilarityQuery) string {
+	limit := "ALL"
+	if q.Limit > 0 {
+		limit = "?"
+	}
+	return fmt.Sprintf(`SELECT id, caption, uri, location, created_at, word_similarity(?, caption) AS score
+		FROM videos
+		WHERE ? <%% caption
+		ORDER BY score %[1]s, id %[1]s
+		LIMIT %[2]s OFFSET ?`,
+		direction(q.Asc), limit,
+	)
+}
+
+func similarityArgs(q *SimilarityQuery) []interface{} {
+	args := []interface{}{q.Phrase, q.Phrase}
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+	}
+	return append(args, q.Offset)
+}
+
+func formatThreshold(threshold float64) string {
+	return strconv.FormatFloat(threshold, 'f', -1, 64)
+}
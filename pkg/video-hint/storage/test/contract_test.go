@@ -0,0 +1,800 @@
+//go:build integration
+// +build integration
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+
+	"github.com/seggga/postgres/migrations"
+	"github.com/seggga/postgres/pkg/migrate"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+// drivers lists every storage.DB implementation that has to pass the contract tests
+var drivers = []storage.Driver{
+	storage.DriverPGX,
+	storage.DriverGorm,
+}
+
+// forEachDriver runs the same check against every backend, so they cannot drift apart
+// on results or error behaviour
+func forEachDriver(t *testing.T, check func(t *testing.T, db storage.DB)) {
+	for _, driver := range drivers {
+		driver := driver
+		t.Run(string(driver), func(t *testing.T) {
+			db, err := storage.NewDB(&storage.Config{
+				Driver:     driver,
+				ConnString: getConnectionString(),
+			})
+			if err != nil {
+				t.Fatalf("failed to create a DB object: %v", err)
+			}
+			defer db.Close()
+			check(t, db)
+		})
+	}
+}
+
+func TestContractNoMatches(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		videos, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: "contract_no_such_caption"})
+		if err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		if videos == nil {
+			t.Fatalf("expected an empty slice, got nil")
+		}
+		if len(videos) != 0 {
+			t.Fatalf("expected no videos, got %d", len(videos))
+		}
+	})
+}
+
+func TestContractClosedDB(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		db.Close()
+		if _, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: "anything"}); err == nil {
+			t.Fatalf("expected an error from a closed DB, got nil")
+		}
+	})
+}
+
+func TestContractCanceledContext(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := db.GetVideosByCaption(ctx, &storage.CaptionQuery{Phrase: "anything"})
+		if !errors.Is(err, storage.ErrQueryCanceled) {
+			t.Fatalf("expected %v, got %v", storage.ErrQueryCanceled, err)
+		}
+		_, err = db.GetVideosByCaptionSimilarity(ctx, &storage.SimilarityQuery{Phrase: "anything", Threshold: 0.3})
+		if !errors.Is(err, storage.ErrQueryCanceled) {
+			t.Fatalf("expected %v, got %v", storage.ErrQueryCanceled, err)
+		}
+	})
+}
+
+func TestContractPagination(t *testing.T) {
+	const phrase = "contract_pagination"
+	insertVideos(t, phrase, []string{"2001-01-01 10:00:00", "2001-01-01 10:00:00", "2002-02-02 10:00:00", "2003-03-03 10:00:00", "2004-04-04 10:00:00"})
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		all, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: phrase})
+		if err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		if len(all) != 5 {
+			t.Fatalf("expected 5 videos, got %d", len(all))
+		}
+		for i := 1; i < len(all); i++ {
+			prev, cur := all[i-1], all[i]
+			if cur.CreatedAt.After(prev.CreatedAt) || (cur.CreatedAt.Equal(prev.CreatedAt) && cur.ID > prev.ID) {
+				t.Fatalf("videos are not ordered by (created_at, id) desc: %v before %v", *prev, *cur)
+			}
+		}
+
+		// walk the pages with a cursor, including the rows sharing created_at
+		var paged []*storage.FoundVideo
+		q := &storage.CaptionQuery{Phrase: phrase, Limit: 2}
+		for {
+			page, err := db.GetVideosByCaption(context.Background(), q)
+			if err != nil {
+				t.Fatalf("GetVideosByCaption failed: %v", err)
+			}
+			paged = append(paged, page...)
+			if len(page) < q.Limit {
+				break
+			}
+			last := page[len(page)-1]
+			q.After = &storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("expected %d videos over all pages, got %d", len(all), len(paged))
+		}
+		for i := range all {
+			if all[i].ID != paged[i].ID {
+				t.Fatalf("video %d: expected id %d, got %d", i, all[i].ID, paged[i].ID)
+			}
+		}
+
+		page, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: phrase, Limit: 2, Offset: 3})
+		if err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != all[3].ID || page[1].ID != all[4].ID {
+			t.Fatalf("offset page does not match the full result")
+		}
+	})
+}
+
+func TestContractSort(t *testing.T) {
+	const phrase = "contract_sort"
+	insertVideos(t, phrase, []string{"2005-05-05 10:00:00", "2006-06-06 10:00:00", "2007-07-07 10:00:00"})
+
+	for _, sort := range []storage.SortField{storage.SortCreatedAt, storage.SortUpdatedAt, storage.SortRelevance, storage.SortLikes} {
+		for _, asc := range []bool{false, true} {
+			sort, asc := sort, asc
+			t.Run(fmt.Sprintf("%s asc=%v", sort, asc), func(t *testing.T) {
+				// results are collected in the order of drivers
+				var results [][]int
+				forEachDriver(t, func(t *testing.T, db storage.DB) {
+					videos, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{
+						Phrase: phrase,
+						Sort:   sort,
+						Asc:    asc,
+					})
+					if err != nil {
+						t.Fatalf("GetVideosByCaption failed: %v", err)
+					}
+					ids := make([]int, len(videos))
+					for i, v := range videos {
+						ids[i] = v.ID
+					}
+					results = append(results, ids)
+				})
+				if len(results) != len(drivers) || len(results[0]) != 3 {
+					t.Fatalf("expected 3 videos from each driver, got %v", results)
+				}
+				for i := 1; i < len(results); i++ {
+					if fmt.Sprint(results[i]) != fmt.Sprint(results[0]) {
+						t.Fatalf("%s returned %v, %s returned %v", drivers[0], results[0], drivers[i], results[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestContractRelevance(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	// the wildcards of the phrase match literally, so the decoy is not found
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_rel/1', 'https://contract-rel.org/1', '480p', 'huge sale 42%_off\', 'contract test video'),
+		(1, '/contract_rel/2', 'https://contract-rel.org/2', '480p', '42%_off\ everything in store', 'contract test video'),
+		(1, '/contract_rel/3', 'https://contract-rel.org/3', '480p', 'deal 42%_off\ today', 'contract test video'),
+		(1, '/contract_rel/4', 'https://contract-rel.org/4', '480p', '42%_off\', 'contract test video'),
+		(1, '/contract_rel/5', 'https://contract-rel.org/5', '480p', '42%xoff\ decoy', 'contract test video')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+	mostRelevant := []string{`42%_off\`, `42%_off\ everything in store`, `deal 42%_off\ today`, `huge sale 42%_off\`}
+
+	for _, asc := range []bool{false, true} {
+		asc := asc
+		t.Run(fmt.Sprintf("asc=%v", asc), func(t *testing.T) {
+			expected := make([]string, len(mostRelevant))
+			copy(expected, mostRelevant)
+			if asc {
+				for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+					expected[i], expected[j] = expected[j], expected[i]
+				}
+			}
+			forEachDriver(t, func(t *testing.T, db storage.DB) {
+				videos, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{
+					Phrase: `42%_off\`,
+					Sort:   storage.SortRelevance,
+					Asc:    asc,
+				})
+				if err != nil {
+					t.Fatalf("GetVideosByCaption failed: %v", err)
+				}
+				captions := make([]string, len(videos))
+				for i, v := range videos {
+					captions[i] = v.Caption
+				}
+				if strings.Join(captions, "|") != strings.Join(expected, "|") {
+					t.Fatalf("expected the order %q, got %q", expected, captions)
+				}
+			})
+		})
+	}
+}
+
+func TestContractFullText(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_fts/1', 'https://contract-fts.org/1', '480p', 'Sauerkraut at home', 'A slow guide to the fermentation of cabbage'),
+		(1, '/contract_fts/2', 'https://contract-fts.org/2', '480p', 'Fermentation basics', 'Everything you need to ferment vegetables')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		videos, err := db.SearchVideos(context.Background(), &storage.TextQuery{Phrase: "fermented"})
+		if err != nil {
+			t.Fatalf("SearchVideos failed: %v", err)
+		}
+		if len(videos) != 2 {
+			t.Fatalf("expected 2 videos, got %d", len(videos))
+		}
+		// a caption match weighs more than a description match
+		if videos[0].Caption != "Fermentation basics" || videos[0].Rank <= videos[1].Rank {
+			t.Fatalf("videos are not ordered by relevance: %v, %v", *videos[0], *videos[1])
+		}
+		if !strings.Contains(videos[1].Snippet, "<b>fermentation</b>") {
+			t.Fatalf("expected a highlighted snippet, got %q", videos[1].Snippet)
+		}
+
+		videos, err = db.SearchVideos(context.Background(), &storage.TextQuery{Phrase: "fermentation -cabbage", Limit: 1})
+		if err != nil {
+			t.Fatalf("SearchVideos failed: %v", err)
+		}
+		if len(videos) != 1 || videos[0].Caption != "Fermentation basics" {
+			t.Fatalf("expected only the video without cabbage, got %v", videos)
+		}
+	})
+}
+
+func TestContractFullTextSnippetEscape(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_fts_escape/1', 'https://contract-fts-escape.org/1', '480p', '<script>alert(1)</script> sourdough', 'Bread & "sourdough" starters')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		videos, err := db.SearchVideos(context.Background(), &storage.TextQuery{Phrase: "sourdough"})
+		if err != nil {
+			t.Fatalf("SearchVideos failed: %v", err)
+		}
+		if len(videos) != 1 {
+			t.Fatalf("expected 1 video, got %d", len(videos))
+		}
+		snippet := videos[0].Snippet
+		if !strings.Contains(snippet, "<b>sourdough</b>") || strings.Contains(snippet, "<script") || strings.Contains(snippet, `"`) {
+			t.Fatalf("expected an escaped snippet with highlighting, got %q", snippet)
+		}
+	})
+}
+
+func TestContractSimilarity(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_trgm/1', 'https://contract-trgm.org/1', '720p', 'Kaleidoscope patterns explained', 'trigram test'),
+		(1, '/contract_trgm/2', 'https://contract-trgm.org/2', '720p', 'Kaleidoscopic views', 'trigram test')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		videos, err := db.GetVideosByCaptionSimilarity(context.Background(), &storage.SimilarityQuery{
+			Phrase:    "kaleidoskope",
+			Threshold: 0.4,
+		})
+		if err != nil {
+			t.Fatalf("GetVideosByCaptionSimilarity failed: %v", err)
+		}
+		if len(videos) == 0 || videos[0].Caption != "Kaleidoscope patterns explained" {
+			t.Fatalf("expected the misspelled caption to be found first, got %v", videos)
+		}
+		for i, v := range videos {
+			if v.Score < 0.4 || v.Score > 1 {
+				t.Fatalf("video %d: score %v is out of the threshold", i, v.Score)
+			}
+			if i > 0 && v.Score > videos[i-1].Score {
+				t.Fatalf("videos are not ordered by score")
+			}
+		}
+
+		videos, err = db.GetVideosByCaptionSimilarity(context.Background(), &storage.SimilarityQuery{
+			Phrase:    "kaleidoskope",
+			Threshold: 1,
+		})
+		if err != nil {
+			t.Fatalf("GetVideosByCaptionSimilarity failed: %v", err)
+		}
+		if len(videos) != 0 {
+			t.Fatalf("expected no videos for threshold 1, got %v", videos)
+		}
+	})
+}
+
+func TestContractCaseAndAccentInsensitive(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_ci/1', 'https://contract-ci.org/1', '1080p', 'Interesting Crème Brûlée recipe', 'case test'),
+		(1, '/contract_ci/2', 'https://contract-ci.org/2', '1080p', 'INTERESTING CREME BRULEE FAILS', 'case test')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		for _, phrase := range []string{"interesting creme brulee", "Crème Brûlée", "CRÈME"} {
+			videos, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: phrase})
+			if err != nil {
+				t.Fatalf("GetVideosByCaption failed: %v", err)
+			}
+			if len(videos) != 2 {
+				t.Fatalf("phrase %q: expected 2 videos, got %d", phrase, len(videos))
+			}
+		}
+	})
+}
+
+func TestContractLikeWildcards(t *testing.T) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(
+		context.Background(),
+		`INSERT INTO videos (user_id, location, uri, res, caption, description) VALUES
+		(1, '/contract_like/1', 'https://contract-like.org/1', '144p', 'wildcard_test 100% pure', 'like test'),
+		(1, '/contract_like/2', 'https://contract-like.org/2', '144p', 'wildcard_test 1000 pure', 'like test'),
+		(1, '/contract_like/3', 'https://contract-like.org/3', '144p', 'wildcardXtest 100 pure', 'like test')`,
+	); err != nil {
+		t.Fatalf("failed to create DB data: %v", err)
+	}
+
+	cases := []struct {
+		Query    storage.CaptionQuery
+		Expected int
+	}{
+		{Query: storage.CaptionQuery{Phrase: "100%"}, Expected: 1},
+		{Query: storage.CaptionQuery{Phrase: "wildcard_test"}, Expected: 2},
+		{Query: storage.CaptionQuery{Phrase: "%"}, Expected: 1},
+		{Query: storage.CaptionQuery{Phrase: "%100_ pure", Pattern: true}, Expected: 2},
+		{Query: storage.CaptionQuery{Phrase: "wildcard_test%", Pattern: true}, Expected: 3},
+		{Query: storage.CaptionQuery{Phrase: `wildcard\_test%pure`, Pattern: true}, Expected: 2},
+	}
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		for i, tc := range cases {
+			q := tc.Query
+			videos, err := db.GetVideosByCaption(context.Background(), &q)
+			if err != nil {
+				t.Fatalf("case %d: GetVideosByCaption failed: %v", i, err)
+			}
+			if len(videos) != tc.Expected {
+				t.Fatalf("case %d: phrase %q (pattern: %v): expected %d videos, got %d", i, q.Phrase, q.Pattern, tc.Expected, len(videos))
+			}
+		}
+	})
+}
+
+func TestContractVideoCRUD(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		name := strings.ReplaceAll(t.Name(), "/", "_")
+		v, err := db.CreateVideo(ctx, &storage.Video{
+			UserID:      1,
+			Location:    "/crud/" + name,
+			URI:         "https://crud.org/" + name,
+			RES:         "720p",
+			Caption:     "crud video",
+			Description: "contract test video",
+		})
+		if err != nil {
+			t.Fatalf("CreateVideo failed: %v", err)
+		}
+		if v.ID == 0 || v.CreatedAt.IsZero() {
+			t.Fatalf("expected the created video to carry its id and creation time, got %+v", v)
+		}
+
+		got, err := db.GetVideo(ctx, v.ID)
+		if err != nil {
+			t.Fatalf("GetVideo failed: %v", err)
+		}
+		if got.URI != v.URI || got.RES != "720p" {
+			t.Fatalf("expected video %+v, got %+v", v, got)
+		}
+
+		caption := "crud video updated"
+		res := "1080p"
+		upd, err := db.UpdateVideo(ctx, v.ID, &storage.VideoUpdate{Caption: &caption, RES: &res})
+		if err != nil {
+			t.Fatalf("UpdateVideo failed: %v", err)
+		}
+		if upd.Caption != caption || upd.RES != res || upd.URI != v.URI {
+			t.Fatalf("expected caption %q and res %q with other fields kept, got %+v", caption, res, upd)
+		}
+
+		dup := *v
+		dup.Location += "_dup"
+		var cErr *storage.ConstraintError
+		if _, err := db.CreateVideo(ctx, &dup); !errors.As(err, &cErr) || !errors.Is(err, storage.ErrConflict) || cErr.Field != "uri" {
+			t.Fatalf("expected a conflict on uri, got %v", err)
+		}
+		dup.URI += "_dup"
+		dup.UserID = -1
+		if _, err := db.CreateVideo(ctx, &dup); !errors.As(err, &cErr) || !errors.Is(err, storage.ErrConstraint) || cErr.Field != "user_id" {
+			t.Fatalf("expected a constraint violation on user_id, got %v", err)
+		}
+
+		if err := db.DeleteVideo(ctx, v.ID); err != nil {
+			t.Fatalf("DeleteVideo failed: %v", err)
+		}
+		if _, err := db.GetVideo(ctx, v.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v after deletion, got %v", storage.ErrNotFound, err)
+		}
+		if _, err := db.UpdateVideo(ctx, v.ID, &storage.VideoUpdate{Caption: &caption}); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v updating a deleted video, got %v", storage.ErrNotFound, err)
+		}
+		if err := db.DeleteVideo(ctx, v.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v deleting a deleted video, got %v", storage.ErrNotFound, err)
+		}
+	})
+}
+
+func TestContractUsers(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		login := strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_"))
+		birthday := time.Date(1990, time.May, 1, 0, 0, 0, 0, time.UTC)
+		u, err := db.CreateUser(ctx, &storage.User{
+			Name:     "Contract",
+			Email:    login + "@example.com",
+			Login:    login,
+			Birthday: birthday,
+		})
+		if err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+		if u.ID == 0 || !u.Birthday.Equal(birthday) || u.About != nil {
+			t.Fatalf("expected the created user with birthday %v and no about, got %+v", birthday, u)
+		}
+
+		dup := *u
+		dup.Login += "_dup"
+		var cErr *storage.ConstraintError
+		if _, err := db.CreateUser(ctx, &dup); !errors.As(err, &cErr) || !errors.Is(err, storage.ErrConflict) || cErr.Field != "email" {
+			t.Fatalf("expected a conflict on email, got %v", err)
+		}
+		dup.Email = "dup_" + dup.Email
+		dup.Login = u.Login
+		if _, err := db.CreateUser(ctx, &dup); !errors.As(err, &cErr) || !errors.Is(err, storage.ErrConflict) || cErr.Field != "login" {
+			t.Fatalf("expected a conflict on login, got %v", err)
+		}
+		dup.Login += "_dup"
+		dup.Birthday = time.Now().AddDate(1, 0, 0)
+		if _, err := db.CreateUser(ctx, &dup); !errors.As(err, &cErr) || !errors.Is(err, storage.ErrConstraint) || cErr.Field != "birthday" {
+			t.Fatalf("expected a constraint violation on birthday, got %v", err)
+		}
+
+		about := "contract test user"
+		upd, err := db.UpdateUser(ctx, u.ID, &storage.UserUpdate{About: &about})
+		if err != nil {
+			t.Fatalf("UpdateUser failed: %v", err)
+		}
+		if upd.About == nil || *upd.About != about || upd.Login != u.Login {
+			t.Fatalf("expected about %q with other fields kept, got %+v", about, upd)
+		}
+		got, err := db.GetUser(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("GetUser failed: %v", err)
+		}
+		if got.About == nil || *got.About != about {
+			t.Fatalf("expected the updated user, got %+v", got)
+		}
+		cleared, err := db.UpdateUser(ctx, u.ID, &storage.UserUpdate{ClearAbout: true})
+		if err != nil {
+			t.Fatalf("UpdateUser failed: %v", err)
+		}
+		if cleared.About != nil || cleared.Login != u.Login {
+			t.Fatalf("expected about to be cleared with other fields kept, got %+v", cleared)
+		}
+		if _, err := db.GetUser(ctx, -1); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+		}
+		if _, err := db.UpdateUser(ctx, -1, &storage.UserUpdate{About: &about}); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v, got %v", storage.ErrNotFound, err)
+		}
+
+		users, err := db.ListUsers(ctx, &storage.UserQuery{AfterID: u.ID - 1, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListUsers failed: %v", err)
+		}
+		if len(users) == 0 || users[0].ID != u.ID {
+			t.Fatalf("expected the page to start with user %d, got %+v", u.ID, users)
+		}
+	})
+}
+
+func TestContractComments(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		name := strings.ReplaceAll(t.Name(), "/", "_")
+		v, err := db.CreateVideo(ctx, &storage.Video{
+			UserID:      1,
+			Location:    "/comments/" + name,
+			URI:         "https://comments.org/" + name,
+			RES:         "360p",
+			Caption:     "commented video",
+			Description: "contract test video",
+		})
+		if err != nil {
+			t.Fatalf("CreateVideo failed: %v", err)
+		}
+		if count, err := db.CountComments(ctx, v.ID); err != nil || count != 0 {
+			t.Fatalf("expected no comments, got %d, %v", count, err)
+		}
+		ids := make([]int, 0, 3)
+		for i := 0; i < 3; i++ {
+			c, err := db.CreateComment(ctx, &storage.Comment{UserID: 1, VideoID: v.ID, Body: fmt.Sprintf("comment #%d", i)})
+			if err != nil {
+				t.Fatalf("CreateComment failed: %v", err)
+			}
+			ids = append(ids, c.ID)
+		}
+		if _, err := db.CreateComment(ctx, &storage.Comment{UserID: 1, VideoID: -1, Body: "orphan"}); !errors.Is(err, storage.ErrConstraint) {
+			t.Fatalf("expected %v commenting a missing video, got %v", storage.ErrConstraint, err)
+		}
+
+		// comments created in a row may share the creation time, the id breaks the ties
+		first, err := db.ListComments(ctx, &storage.CommentQuery{VideoID: v.ID, Asc: true, Limit: 2})
+		if err != nil {
+			t.Fatalf("ListComments failed: %v", err)
+		}
+		if len(first) != 2 || first[0].ID != ids[0] || first[1].ID != ids[1] {
+			t.Fatalf("expected comments %v first, got %+v", ids[:2], first)
+		}
+		last := first[len(first)-1]
+		rest, err := db.ListComments(ctx, &storage.CommentQuery{
+			VideoID: v.ID,
+			Asc:     true,
+			Limit:   2,
+			After:   &storage.Cursor{CreatedAt: last.CreatedAt, ID: last.ID},
+		})
+		if err != nil {
+			t.Fatalf("ListComments failed: %v", err)
+		}
+		if len(rest) != 1 || rest[0].ID != ids[2] {
+			t.Fatalf("expected comment %d on the last page, got %+v", ids[2], rest)
+		}
+
+		if _, err := db.UpdateComment(ctx, ids[0], 2, "not mine"); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v editing a comment of another user, got %v", storage.ErrNotFound, err)
+		}
+		c, err := db.UpdateComment(ctx, ids[0], 1, "edited")
+		if err != nil || c.Body != "edited" {
+			t.Fatalf("expected the comment to be edited, got %+v, %v", c, err)
+		}
+		if err := db.DeleteComment(ctx, ids[1], 2); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v deleting a comment of another user, got %v", storage.ErrNotFound, err)
+		}
+		if err := db.DeleteComment(ctx, ids[1], 1); err != nil {
+			t.Fatalf("DeleteComment failed: %v", err)
+		}
+		if _, err := db.GetComment(ctx, ids[1]); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v after deletion, got %v", storage.ErrNotFound, err)
+		}
+		if count, err := db.CountComments(ctx, v.ID); err != nil || count != 2 {
+			t.Fatalf("expected 2 comments, got %d, %v", count, err)
+		}
+		if _, err := db.CountComments(ctx, -1); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %v counting comments of a missing video, got %v", storage.ErrNotFound, err)
+		}
+		if err := db.DeleteVideo(ctx, v.ID); !errors.Is(err, storage.ErrConstraint) {
+			t.Fatalf("expected %v deleting a commented video, got %v", storage.ErrConstraint, err)
+		}
+	})
+}
+
+func TestContractVotes(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		name := strings.ReplaceAll(t.Name(), "/", "_")
+		videos := make([]*storage.Video, 2)
+		for i := range videos {
+			v, err := db.CreateVideo(ctx, &storage.Video{
+				UserID:      1,
+				Location:    fmt.Sprintf("/votes/%s/%d", name, i),
+				URI:         fmt.Sprintf("https://votes.org/%s/%d", name, i),
+				RES:         "480p",
+				Caption:     "voted video",
+				Description: "contract test video",
+			})
+			if err != nil {
+				t.Fatalf("CreateVideo failed: %v", err)
+			}
+			videos[i] = v
+		}
+		first, second := videos[0].ID, videos[1].ID
+
+		// voting twice is idempotent, the second vote of a user replaces the first one
+		for _, vote := range []struct {
+			userID  int
+			videoID int
+			thumbUp bool
+		}{
+			{1, first, true},
+			{1, first, true},
+			{2, first, true},
+			{3, first, false},
+			{3, first, true},
+			{4, first, false},
+			{1, second, false},
+		} {
+			if err := db.Vote(ctx, vote.userID, vote.videoID, vote.thumbUp); err != nil {
+				t.Fatalf("Vote failed: %v", err)
+			}
+		}
+		if err := db.Unvote(ctx, 4, first); err != nil {
+			t.Fatalf("Unvote failed: %v", err)
+		}
+		if err := db.Unvote(ctx, 4, first); err != nil {
+			t.Fatalf("repeated Unvote failed: %v", err)
+		}
+		if err := db.Vote(ctx, 1, -1, true); !errors.Is(err, storage.ErrConstraint) {
+			t.Fatalf("expected %v voting for a missing video, got %v", storage.ErrConstraint, err)
+		}
+
+		votes, err := db.CountVotes(ctx, []int{first, second, -1})
+		if err != nil {
+			t.Fatalf("CountVotes failed: %v", err)
+		}
+		expected := map[int]storage.Votes{
+			first:  {Likes: 3},
+			second: {Dislikes: 1},
+		}
+		if fmt.Sprint(votes) != fmt.Sprint(expected) {
+			t.Fatalf("expected votes %v, got %v", expected, votes)
+		}
+	})
+}
+
+func TestContractHealth(t *testing.T) {
+	ms, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("failed to load the migrations: %v", err)
+	}
+	latest := ms[len(ms)-1].Version
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		if err := db.Ping(ctx); err != nil {
+			t.Fatalf("Ping failed: %v", err)
+		}
+		version, dirty, err := db.SchemaVersion(ctx)
+		if err != nil {
+			t.Fatalf("SchemaVersion failed: %v", err)
+		}
+		if version != latest || dirty {
+			t.Fatalf("expected clean version %d, got %d, dirty: %v", latest, version, dirty)
+		}
+		if stats := db.Stats(); stats.TotalConns <= 0 || stats.Saturated() {
+			t.Fatalf("expected an open pool with spare connections, got %+v", stats)
+		}
+	})
+}
+
+func TestContractTracing(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		ctx, root := tp.Tracer("contract").Start(context.Background(), "search")
+		if _, err := db.GetVideosByCaption(ctx, &storage.CaptionQuery{Phrase: "contract_traced"}); err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		if _, err := db.GetVideo(ctx, -1); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+		// statements outside of a traced request are not traced
+		if _, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: "contract_traced"}); err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		root.End()
+
+		var statements []string
+		for _, span := range exporter.GetSpans() {
+			if span.Name == "search" {
+				continue
+			}
+			if span.Parent.SpanID() != root.SpanContext().SpanID() {
+				t.Errorf("expected %s to be a child of the request span", span.Name)
+			}
+			if span.Status.Code == codes.Error {
+				t.Errorf("expected %s to succeed, got: %s", span.Name, span.Status.Description)
+			}
+			attrs := map[attribute.Key]string{}
+			for _, attr := range span.Attributes {
+				attrs[attr.Key] = attr.Value.Emit()
+			}
+			if attrs[semconv.DBSystemKey] != "postgresql" {
+				t.Errorf("expected %s to be a postgresql span, got: %v", span.Name, attrs)
+			}
+			statements = append(statements, attrs[semconv.DBStatementKey])
+		}
+		if len(statements) != 2 {
+			t.Fatalf("expected a span per statement of the traced calls, got: %q", statements)
+		}
+		if !strings.Contains(statements[0], "caption") || !strings.Contains(statements[1], "videos") {
+			t.Errorf("expected the spans to carry the statements, got: %q", statements)
+		}
+	})
+}
+
+// insertVideos creates a video per creation time, captions contain the given phrase
+func insertVideos(t *testing.T, phrase string, createdAt []string) {
+	conn, err := getDBConnector()
+	if err != nil {
+		t.Fatalf("failed to get a connector to the DB: %v", err)
+	}
+	defer conn.Close()
+	for i, ts := range createdAt {
+		if _, err := conn.Exec(
+			context.Background(),
+			`INSERT INTO videos (user_id, location, uri, res, caption, description, created_at)
+			VALUES (1, $1, $2, '360p', $3, 'contract test video', $4)`,
+			fmt.Sprintf("/%s/%d", phrase, i),
+			fmt.Sprintf("https://%s.org/%d", phrase, i),
+			fmt.Sprintf("%s video #%d", phrase, i),
+			ts,
+		); err != nil {
+			t.Fatalf("failed to create DB data: %v", err)
+		}
+	}
+}
+
+func TestUnknownDriver(t *testing.T) {
+	_, err := storage.NewDB(&storage.Config{
+		Driver:     "mysql",
+		ConnString: getConnectionString(),
+	})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown driver, got nil")
+	}
+}
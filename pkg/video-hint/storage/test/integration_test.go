@@ -20,7 +20,9 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
-	"github.com/seggga/postgres/pkg/videos-hint/storage"
+	"github.com/seggga/postgres/migrations"
+	"github.com/seggga/postgres/pkg/migrate"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
 )
 
 const (
@@ -82,17 +84,10 @@ func setup() (r *setupResult, err error) {
 		}
 	}()
 
-	migrationContainer, err := runMigrationContainer(pool, testFileDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to run the migration container: %w", err)
+	if err := migrateDB(); err != nil {
+		return nil, fmt.Errorf("failed to migrate the DB: %w", err)
 	}
 
-	defer func() {
-		if err := pool.Purge(migrationContainer); err != nil {
-			err = fmt.Errorf("failed to purge the migration container: %w", err)
-		}
-	}()
-
 	if err := pool.Retry(func() error {
 		err := prepopulateDB(testFileDir)
 		if err != nil {
@@ -164,42 +159,18 @@ func runPostgresContainer(pool *dockertest.Pool, testFileDir string) (*dockertes
 	return postgresContainer, nil
 }
 
-func runMigrationContainer(pool *dockertest.Pool, testFileDir string) (*dockertest.Resource, error) {
-	migrationsDir, err := filepath.Abs(filepath.Join(testFileDir, "../../../../migrations"))
+// migrateDB applies the migrations embedded in the service binary
+func migrateDB() error {
+	ms, err := migrate.Load(migrations.FS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the absolute path of the migrations dir: %w", err)
+		return fmt.Errorf("failed to load the migrations: %w", err)
 	}
-	migrationContainer, err := pool.RunWithOptions(
-		&dockertest.RunOptions{
-			Repository: "migrate/migrate",
-			Tag:        "v4.15.0",
-			Cmd: []string{
-				"-path=/migrations",
-				fmt.Sprintf(
-					"-database=%s",
-					composeConnectionString(),
-				),
-				"up",
-			},
-		},
-		func(config *docker.HostConfig) {
-			config.AutoRemove = false
-			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
-			config.Mounts = []docker.HostMount{
-				{
-					Target: "/migrations",
-					Source: migrationsDir,
-					Type:   "bind",
-				},
-			}
-			config.NetworkMode = "host"
-		},
-	)
+	conn, err := storage.ConnectPGX(context.Background(), getConnectionString())
 	if err != nil {
-		return nil, fmt.Errorf("failed to start the migration container: %w", err)
+		return fmt.Errorf("failed to connect to the DB: %w", err)
 	}
-
-	return migrationContainer, err
+	defer conn.Close(context.Background())
+	return migrate.New(conn, ms, nil).Up(context.Background())
 }
 
 func prepopulateDB(testFileDir string) error {
@@ -272,30 +243,68 @@ func TestGetVideosByCaption(t *testing.T) {
 		t.Fatalf("failed to create DB data: %v", err)
 	}
 
-	db, err := storage.NewDB(getConnectionString())
+	sort.Slice(videosForTest, func(i int, j int) bool {
+		return videosForTest[i].Caption < videosForTest[j].Caption
+	})
+
+	forEachDriver(t, func(t *testing.T, db storage.DB) {
+		videos, err := db.GetVideosByCaption(context.Background(), &storage.CaptionQuery{Phrase: captionTestSubstring})
+		if err != nil {
+			t.Fatalf("GetVideosByCaption failed: %v", err)
+		}
+		if len(videosForTest) != len(videos) {
+			t.Fatalf("wrong number of found videos: expected %d, got %d", len(videosForTest), len(videos))
+		}
+
+		// the order of rows is not defined, so compare sorted slices
+		sort.Slice(videos, func(i int, j int) bool {
+			return videos[i].Caption < videos[j].Caption
+		})
+		for i, tst := range videosForTest {
+			v := *videos[i]
+			if tst.Caption != v.Caption || tst.URI != v.URI || tst.Location != v.Location {
+				t.Fatalf("expected object %v is not equal to the actual object %v", tst, v)
+			}
+		}
+	})
+}
+
+func TestCaptionSearchUsesIndex(t *testing.T) {
+	conn, err := getDBConnector()
 	if err != nil {
-		t.Fatalf("failed to create a DB object: %v", err)
+		t.Fatalf("failed to get a connector to the DB: %v", err)
 	}
-	videos, err := db.GetVideosByCaption(context.Background(), captionTestSubstring)
+	defer conn.Close()
+
+	tx, err := conn.Begin(context.Background())
 	if err != nil {
-		t.Fatalf("GetVideosByCaption failed: %v", err)
+		t.Fatalf("failed to begin a transaction: %v", err)
 	}
-	if len(videosForTest) != len(videos) {
-		t.Fatalf("wrong number of found videos: expected %d, got %d", len(videosForTest), len(videos))
+	defer tx.Rollback(context.Background())
+	// the test table is small, so the planner would prefer a sequential scan
+	if _, err := tx.Exec(context.Background(), "SET LOCAL enable_seqscan = off"); err != nil {
+		t.Fatalf("failed to disable sequential scans: %v", err)
 	}
-
-	// todo попытаться понять, для чего вообще эти строки
-	sort.Slice(videosForTest, func(i int, j int) bool {
-		return videosForTest[i].Caption < videosForTest[j].Caption
-	})
-	sort.Slice(videos, func(i int, j int) bool {
-		return videos[i].Caption < videos[j].Caption
-	})
-	for i, tst := range videosForTest {
-		v := *videos[i]
-		if tst != v {
-			t.Fatalf("expected object %v is not equal to the actual object %v", tst, v)
+	rows, err := tx.Query(
+		context.Background(),
+		`EXPLAIN SELECT id FROM videos
+		WHERE lower(immutable_unaccent(videos.caption)) LIKE '%' || lower(immutable_unaccent($1)) || '%'`,
+		"Crème",
+	)
+	if err != nil {
+		t.Fatalf("failed to explain the caption search: %v", err)
+	}
+	defer rows.Close()
+	var plan strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			t.Fatalf("failed to read the plan: %v", err)
 		}
+		plan.WriteString(line + "\n")
+	}
+	if !strings.Contains(plan.String(), "videos_caption_lower_unaccent_idx") {
+		t.Fatalf("expected the caption search to use videos_caption_lower_unaccent_idx, got plan:\n%s", plan.String())
 	}
 }
 
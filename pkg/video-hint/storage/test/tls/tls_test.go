@@ -0,0 +1,296 @@
+//go:build integration
+// +build integration
+
+package storage
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/seggga/postgres/pkg/video-hint/storage"
+)
+
+const (
+	DB_USER     = "gotuber"
+	DB_PASSWORD = "Passw0rd"
+	DB_NAME     = "go_tube"
+
+	// serverName is the only name in the server certificate
+	serverName = "localhost"
+)
+
+var (
+	DB_PORT  = ""
+	certsDir = ""
+)
+
+const dockerMaxWait = time.Second * 10
+
+func TestMain(m *testing.M) {
+	os.Exit(testMain(m))
+}
+
+func testMain(m *testing.M) int {
+	var err error
+	certsDir, err = os.MkdirTemp("", "pg-tls")
+	if err != nil {
+		log.Println("failed to create the certs dir: ", err)
+		return -1
+	}
+	defer os.RemoveAll(certsDir)
+	if err := writeCerts(certsDir); err != nil {
+		log.Println("failed to write the certs: ", err)
+		return -1
+	}
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		log.Println("failed to create a new dockertest pool: ", err)
+		return -1
+	}
+	pool.MaxWait = dockerMaxWait
+	postgresContainer, err := runPostgresContainer(pool)
+	if err != nil {
+		log.Println("failed to run the Postgres container: ", err)
+		return -1
+	}
+	defer func() {
+		if err := pool.Purge(postgresContainer); err != nil {
+			log.Printf("failed to purge the Postgres container: %v", err)
+		}
+	}()
+	return m.Run()
+}
+
+// runPostgresContainer starts Postgres with TLS on. The server refuses a key readable by
+// others or owned by another user, so the mounted files are copied and chowned first.
+// ssl_ca_file makes the server check the client certificates it is given
+func runPostgresContainer(pool *dockertest.Pool) (*dockertest.Resource, error) {
+	const script = `cp /certs/server.crt /certs/server.key /certs/ca.crt /var/lib/postgresql/ &&
+chown postgres:postgres /var/lib/postgresql/server.key && chmod 600 /var/lib/postgresql/server.key &&
+exec docker-entrypoint.sh postgres -c ssl=on \
+	-c ssl_cert_file=/var/lib/postgresql/server.crt \
+	-c ssl_key_file=/var/lib/postgresql/server.key \
+	-c ssl_ca_file=/var/lib/postgresql/ca.crt`
+	postgresContainer, err := pool.RunWithOptions(
+		&dockertest.RunOptions{
+			Repository: "postgres",
+			Tag:        "14.0",
+			Env: []string{
+				"POSTGRES_USER=" + DB_USER,
+				"POSTGRES_PASSWORD=" + DB_PASSWORD,
+				"POSTGRES_DB=" + DB_NAME,
+			},
+			Mounts:     []string{certsDir + ":/certs:ro"},
+			Entrypoint: []string{"bash", "-c", script},
+		},
+		func(config *docker.HostConfig) {
+			config.AutoRemove = false
+			config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the postgres docker container: %w", err)
+	}
+	postgresContainer.Expire(120)
+
+	DB_PORT = postgresContainer.GetPort("5432/tcp")
+
+	// Wait for the DB to start
+	if err := pool.Retry(func() error {
+		conn, err := storage.ConnectPGX(context.Background(), connString("verify-full", "ca.crt"))
+		if err != nil {
+			return err
+		}
+		return conn.Close(context.Background())
+	}); err != nil {
+		pool.Purge(postgresContainer)
+		return nil, fmt.Errorf("failed to connect to the created DB: %w", err)
+	}
+	return postgresContainer, nil
+}
+
+// connString connects to serverName with the client certificate, rootCert is a file
+// of certsDir
+func connString(sslMode, rootCert string) *storage.ConnString {
+	return &storage.ConnString{
+		Host:        serverName,
+		Port:        DB_PORT,
+		User:        DB_USER,
+		Password:    DB_PASSWORD,
+		DBName:      DB_NAME,
+		SSLMode:     sslMode,
+		SSLRootCert: filepath.Join(certsDir, rootCert),
+		SSLCert:     filepath.Join(certsDir, "client.crt"),
+		SSLKey:      filepath.Join(certsDir, "client.key"),
+	}
+}
+
+// TestTLSSession checks the session is encrypted and the server got the client certificate
+func TestTLSSession(t *testing.T) {
+	ctx := context.Background()
+	conn, err := storage.ConnectPGX(ctx, connString("verify-full", "ca.crt"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	var ssl bool
+	var clientDN *string
+	if err := conn.QueryRow(ctx, "SELECT ssl, client_dn FROM pg_stat_ssl WHERE pid = pg_backend_pid()").Scan(&ssl, &clientDN); err != nil {
+		t.Fatalf("failed to query pg_stat_ssl: %v", err)
+	}
+	if !ssl {
+		t.Fatalf("expected the session to use TLS")
+	}
+	if clientDN == nil || !strings.Contains(*clientDN, "CN="+DB_USER) {
+		t.Errorf("expected the server to get the client certificate, got client_dn: %v", clientDN)
+	}
+}
+
+func TestTLSDrivers(t *testing.T) {
+	cases := []struct {
+		Name      string
+		Conn      func() *storage.ConnString
+		ExpectErr bool
+	}{
+		{
+			Name: "verify-full",
+			Conn: func() *storage.ConnString { return connString("verify-full", "ca.crt") },
+		},
+		{
+			Name: "verify-ca ignores the host name",
+			Conn: func() *storage.ConnString {
+				c := connString("verify-ca", "ca.crt")
+				c.Host = "127.0.0.1"
+				return c
+			},
+		},
+		{
+			Name: "verify-full checks the host name",
+			Conn: func() *storage.ConnString {
+				c := connString("verify-full", "ca.crt")
+				c.Host = "127.0.0.1"
+				return c
+			},
+			ExpectErr: true,
+		},
+		{
+			Name:      "unknown CA",
+			Conn:      func() *storage.ConnString { return connString("verify-full", "other-ca.crt") },
+			ExpectErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			for _, driver := range []storage.Driver{storage.DriverPGX, storage.DriverGorm} {
+				db, err := storage.NewDB(&storage.Config{
+					Driver:     driver,
+					ConnString: tc.Conn(),
+				})
+				if tc.ExpectErr {
+					if err == nil {
+						db.Close()
+						t.Errorf("%s: expected an error", driver)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("%s: failed to connect: %v", driver, err)
+					continue
+				}
+				db.Close()
+			}
+		})
+	}
+}
+
+// writeCerts writes a CA, the server and client certificates it signed and an unrelated
+// CA into dir
+func writeCerts(dir string) error {
+	ca, caKey, err := newCert(nil, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, dir, "ca")
+	if err != nil {
+		return err
+	}
+	if _, _, err := newCert(ca, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: serverName},
+		DNSNames:    []string{serverName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, dir, "server"); err != nil {
+		return err
+	}
+	if _, _, err := newCert(ca, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: DB_USER},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, dir, "client"); err != nil {
+		return err
+	}
+	_, _, err = newCert(nil, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "other CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, dir, "other-ca")
+	return err
+}
+
+// newCert signs tmpl by parent, a nil parent makes it self-signed, and writes
+// <name>.crt and <name>.key into dir
+func newCert(parent *x509.Certificate, parentKey *ecdsa.PrivateKey, tmpl *x509.Certificate, dir, name string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate the %s key: %w", name, err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate the %s serial number: %w", name, err)
+	}
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour * 24)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the %s cert: %w", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the %s cert: %w", name, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal the %s key: %w", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
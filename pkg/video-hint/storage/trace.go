@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracerName = "github.com/seggga/postgres/pkg/video-hint/storage"
+
+// startSpan starts a span of a statement, only within a traced request
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan ends the span of a statement, a missing row is a result rather than a failure
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedPool starts a span per statement, pgx v4 has no tracer hook
+type tracedPool struct {
+	*pgxpool.Pool
+}
+
+func (p *tracedPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx}, nil
+}
+
+func (p *tracedPool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return tracedExec(ctx, p.Pool.Exec, sql, args...)
+}
+
+func (p *tracedPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tracedQuery(ctx, p.Pool.Query, sql, args...)
+}
+
+func (p *tracedPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return tracedQueryRow(ctx, p.Pool.QueryRow, sql, args...)
+}
+
+type tracedTx struct {
+	pgx.Tx
+}
+
+func (tx *tracedTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return tracedExec(ctx, tx.Tx.Exec, sql, args...)
+}
+
+func (tx *tracedTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tracedQuery(ctx, tx.Tx.Query, sql, args...)
+}
+
+func (tx *tracedTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return tracedQueryRow(ctx, tx.Tx.QueryRow, sql, args...)
+}
+
+func tracedExec(ctx context.Context, exec func(context.Context, string, ...interface{}) (pgconn.CommandTag, error), sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startSpan(ctx, "pgx.exec", semconv.DBStatementKey.String(sql))
+	tag, err := exec(ctx, sql, args...)
+	endSpan(span, err)
+	return tag, err
+}
+
+func tracedQuery(ctx context.Context, query func(context.Context, string, ...interface{}) (pgx.Rows, error), sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startSpan(ctx, "pgx.query", semconv.DBStatementKey.String(sql))
+	rows, err := query(ctx, sql, args...)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func tracedQueryRow(ctx context.Context, queryRow func(context.Context, string, ...interface{}) pgx.Row, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startSpan(ctx, "pgx.query_row", semconv.DBStatementKey.String(sql))
+	return &tracedRow{row: queryRow(ctx, sql, args...), span: span}
+}
+
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	if !r.ended {
+		r.ended = true
+		endSpan(r.span, r.Rows.Err())
+	}
+}
+
+type tracedRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	endSpan(r.span, err)
+	return err
+}
+
+const gormSpanKey = "tracing:span"
+
+// gormTracing is a gorm plugin starting a span per statement
+type gormTracing struct{}
+
+func (gormTracing) Name() string {
+	return "tracing"
+}
+
+func (gormTracing) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", beforeGormStatement("gorm.create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", afterGormStatement),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", beforeGormStatement("gorm.query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", afterGormStatement),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", beforeGormStatement("gorm.update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", afterGormStatement),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", beforeGormStatement("gorm.delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", afterGormStatement),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", beforeGormStatement("gorm.row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", afterGormStatement),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", beforeGormStatement("gorm.raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", afterGormStatement),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeGormStatement starts the span, the statement is only known once gorm has built it
+func beforeGormStatement(name string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := startSpan(tx.Statement.Context, name)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func afterGormStatement(tx *gorm.DB) {
+	val, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := val.(trace.Span)
+	span.SetAttributes(semconv.DBStatementKey.String(tx.Statement.SQL.String()))
+	endSpan(span, tx.Error)
+}
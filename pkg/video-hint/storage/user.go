@@ -0,0 +1,57 @@
+package storage
+
+import "time"
+
+// User is a row of the users table, About is nil until the user fills it in
+type User struct {
+	ID       int       `gorm:"column:id" json:"id"`
+	Name     string    `gorm:"column:name" json:"name"`
+	Email    string    `gorm:"column:email" json:"email"`
+	Login    string    `gorm:"column:login" json:"login"`
+	Birthday time.Time `gorm:"column:birthday" json:"birthday"`
+	About    *string   `gorm:"column:about" json:"about"`
+}
+
+// UserUpdate holds the user fields to change, nil fields are kept as they are and ClearAbout empties About
+type UserUpdate struct {
+	Name       *string
+	Email      *string
+	Login      *string
+	Birthday   *time.Time
+	About      *string
+	ClearAbout bool
+}
+
+// UserQuery describes one page of users ordered by id, the page starts after the AfterID user
+type UserQuery struct {
+	AfterID int
+	Limit   int
+}
+
+const userColumns = `id, name, email, login, birthday, about`
+
+const createUserSQL = `INSERT INTO users (name, email, login, birthday, about)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING ` + userColumns
+
+const getUserSQL = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+
+const updateUserSQL = `UPDATE users SET
+		name = COALESCE(?, name),
+		email = COALESCE(?, email),
+		login = COALESCE(?, login),
+		birthday = COALESCE(?, birthday),
+		about = CASE WHEN ? THEN NULL ELSE COALESCE(?, about) END
+	WHERE id = ?
+	RETURNING ` + userColumns
+
+// listUsersSQL pages through users_pkey, so deep pages cost as much as the first one
+const listUsersSQL = `SELECT ` + userColumns + ` FROM users WHERE id > ? ORDER BY id LIMIT ?`
+
+func createUserArgs(u *User) []interface{} {
+	return []interface{}{u.Name, u.Email, u.Login, u.Birthday, u.About}
+}
+
+func updateUserArgs(id int, upd *UserUpdate) []interface{} {
+	return []interface{}{upd.Name, upd.Email, upd.Login, upd.Birthday, upd.ClearAbout, upd.About, id}
+}
@@ -0,0 +1,39 @@
+package storage
+
+// VideoUpdate holds the video fields to change, nil fields are kept as they are
+type VideoUpdate struct {
+	Location    *string
+	URI         *string
+	RES         *string
+	Caption     *string
+	Description *string
+}
+
+const videoColumns = `id, user_id, location, uri, res, caption, description, created_at, updated_at`
+
+// created_at and updated_at are left to the DB, so the NOW() checks do not depend on the service clock
+const createVideoSQL = `INSERT INTO videos (user_id, location, uri, res, caption, description)
+	VALUES (?, ?, ?, ?, ?, ?)
+	RETURNING ` + videoColumns
+
+const getVideoSQL = `SELECT ` + videoColumns + ` FROM videos WHERE id = ?`
+
+const updateVideoSQL = `UPDATE videos SET
+		location = COALESCE(?, location),
+		uri = COALESCE(?, uri),
+		res = COALESCE(?::resolution, res),
+		caption = COALESCE(?, caption),
+		description = COALESCE(?, description),
+		updated_at = NOW()
+	WHERE id = ?
+	RETURNING ` + videoColumns
+
+const deleteVideoSQL = `DELETE FROM videos WHERE id = ?`
+
+func createVideoArgs(v *Video) []interface{} {
+	return []interface{}{v.UserID, v.Location, v.URI, v.RES, v.Caption, v.Description}
+}
+
+func updateVideoArgs(id int, upd *VideoUpdate) []interface{} {
+	return []interface{}{upd.Location, upd.URI, upd.RES, upd.Caption, upd.Description, id}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import "github.com/jackc/pgtype"
+
+// Votes holds the like and dislike counts of a video
+type Votes struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// voteSQL casts or recasts the vote of a user, so repeating it changes nothing
+const voteSQL = `INSERT INTO likes (user_id, video_id, thumb_up)
+	VALUES (?, ?, ?)
+	ON CONFLICT (user_id, video_id) DO UPDATE SET thumb_up = EXCLUDED.thumb_up`
+
+const unvoteSQL = `DELETE FROM likes WHERE user_id = ? AND video_id = ?`
+
+// countVotesSQL counts the votes of a page of videos at once, it is served by likes_video_id_idx
+const countVotesSQL = `SELECT video_id,
+		count(*) FILTER (WHERE thumb_up) AS likes,
+		count(*) FILTER (WHERE NOT thumb_up) AS dislikes
+	FROM likes
+	WHERE video_id = ANY(?::int[])
+	GROUP BY video_id`
+
+// videoVotes is a row of countVotesSQL
+type videoVotes struct {
+	VideoID  int
+	Likes    int
+	Dislikes int
+}
+
+// videoIDsArg binds the ids as a single array parameter, both drivers pass it as is
+func videoIDsArg(ids []int) (*pgtype.Int4Array, error) {
+	arg := &pgtype.Int4Array{}
+	if err := arg.Set(ids); err != nil {
+		return nil, err
+	}
+	return arg, nil
+}
@@ -1 +0,0 @@
-ALTER TABLE users DROP COLUMN about;
\ No newline at end of file
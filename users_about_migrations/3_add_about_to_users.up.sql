@@ -1 +0,0 @@
-ALTER TABLE users ADD COLUMN about TEXT;
\ No newline at end of file